@@ -0,0 +1,117 @@
+package gcs
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectAttributes describes an object as reported by the headers of a response to a GET, HEAD, or PUT request.
+// https://cloud.google.com/storage/docs/xml-api/reference-headers
+type ObjectAttributes struct {
+	Size            int64 // as stored, even when transcoded (see x-goog-stored-content-length)
+	ContentType     string
+	ContentEncoding string
+	CacheControl    string
+	ETag            string
+	Generation      int64
+	Metageneration  int64
+	CRC32C          uint32 // zero when not reported
+	MD5             []byte // nil when not reported (e.g. composite objects)
+	StorageClass    string
+	Metadata        map[string]string // custom x-goog-meta-* headers, keyed by lower-case name without the prefix
+	LastModified    time.Time
+}
+
+// ParseObjectAttributes reads the attributes of an object from the headers of a successful response.
+func ParseObjectAttributes(response *http.Response) (ObjectAttributes, error) {
+	headers := response.Header
+	attributes := ObjectAttributes{
+		Size:            response.ContentLength,
+		ContentType:     headers.Get(headerContentType),
+		ContentEncoding: headers.Get(headerContentEncoding),
+		CacheControl:    headers.Get(headerCacheControl),
+		ETag:            headers.Get(headerETag),
+		StorageClass:    headers.Get(headerStorageClass),
+	}
+
+	var err error
+	if attributes.Size, err = parseAttributeInt(headers, headerStoredContentLength, attributes.Size); err != nil {
+		return ObjectAttributes{}, err
+	} else if attributes.Generation, err = parseAttributeInt(headers, headerObjectGeneration, 0); err != nil {
+		return ObjectAttributes{}, err
+	} else if attributes.Metageneration, err = parseAttributeInt(headers, headerObjectMetageneration, 0); err != nil {
+		return ObjectAttributes{}, err
+	} else if err = attributes.parseHashes(headers.Values(headerHash)); err != nil {
+		return ObjectAttributes{}, err
+	}
+
+	if value := headers.Get(headerLastModified); len(value) > 0 {
+		if attributes.LastModified, err = http.ParseTime(value); err != nil {
+			return ObjectAttributes{}, fmt.Errorf("%w [%s]: %w", ErrMalformedAttributes, headerLastModified, err)
+		}
+	}
+
+	for name, values := range headers {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, headerMetadataPrefix) && len(values) > 0 {
+			if attributes.Metadata == nil {
+				attributes.Metadata = make(map[string]string)
+			}
+			attributes.Metadata[strings.TrimPrefix(lower, headerMetadataPrefix)] = values[0]
+		}
+	}
+
+	return attributes, nil
+}
+func parseAttributeInt(headers http.Header, name string, fallback int64) (int64, error) {
+	value := headers.Get(name)
+	if len(value) == 0 {
+		return fallback, nil
+	} else if parsed, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return 0, fmt.Errorf("%w [%s]: %w", ErrMalformedAttributes, name, err)
+	} else {
+		return parsed, nil
+	}
+}
+
+// parseHashes reads the (base64-encoded) checksums of x-goog-hash, which may be repeated or comma-separated, e.g.
+// "crc32c=n03x6A==,md5=Ojk9c3dhfxgoKVVHYwFbHQ==".
+func (this *ObjectAttributes) parseHashes(values []string) error {
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			name, encoded, _ := strings.Cut(strings.TrimSpace(item), "=")
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return fmt.Errorf("%w [%s]: %w", ErrMalformedAttributes, headerHash, err)
+			}
+
+			switch strings.ToLower(name) {
+			case "crc32c":
+				if len(decoded) != 4 {
+					return fmt.Errorf("%w [%s]: crc32c must be four bytes", ErrMalformedAttributes, headerHash)
+				}
+				this.CRC32C = binary.BigEndian.Uint32(decoded)
+			case "md5":
+				this.MD5 = decoded
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	headerETag                 = "ETag"
+	headerCacheControl         = "Cache-Control"
+	headerLastModified         = "Last-Modified"
+	headerHash                 = "x-goog-hash"
+	headerStorageClass         = "x-goog-storage-class"
+	headerObjectMetageneration = "x-goog-metageneration"
+	headerMetadataPrefix       = "x-goog-meta-"
+)
+
+var ErrMalformedAttributes = errors.New("malformed object attributes")
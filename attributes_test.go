@@ -0,0 +1,90 @@
+package gcs
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestParseObjectAttributes(t *testing.T) {
+	response := newFakeResponse(http.StatusOK, "", http.Header{
+		"Content-Type":                 {"text/plain"},
+		"Content-Encoding":             {"gzip"},
+		"Cache-Control":                {"public, max-age=3600"},
+		"Etag":                         {`"3a393d737761f182829554763015b1d"`},
+		"Last-Modified":                {"Tue, 04 Apr 2023 20:47:09 GMT"},
+		"X-Goog-Generation":            {"1700000000000001"},
+		"X-Goog-Metageneration":        {"3"},
+		"X-Goog-Hash":                  {"crc32c=n03x6A==", "md5=Ojk9c3dhfxgoKVVHYwFbHQ=="},
+		"X-Goog-Stored-Content-Length": {"1024"},
+		"X-Goog-Storage-Class":         {"NEARLINE"},
+		"X-Goog-Meta-Build-Number":     {"42"},
+		"X-Goog-Meta-Origin":           {"ci"},
+	})
+	response.ContentLength = 512 // transcoded
+
+	attributes, err := ParseObjectAttributes(response)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, attributes, should.Equal, ObjectAttributes{
+		Size:            1024,
+		ContentType:     "text/plain",
+		ContentEncoding: "gzip",
+		CacheControl:    "public, max-age=3600",
+		ETag:            `"3a393d737761f182829554763015b1d"`,
+		Generation:      1700000000000001,
+		Metageneration:  3,
+		CRC32C:          0x9f4df1e8,
+		MD5:             []byte{0x3a, 0x39, 0x3d, 0x73, 0x77, 0x61, 0x7f, 0x18, 0x28, 0x29, 0x55, 0x47, 0x63, 0x01, 0x5b, 0x1d},
+		StorageClass:    "NEARLINE",
+		Metadata:        map[string]string{"build-number": "42", "origin": "ci"},
+		LastModified:    time.Date(2023, 4, 4, 20, 47, 9, 0, time.UTC),
+	})
+}
+func TestParseObjectAttributes_CommaSeparatedHashes(t *testing.T) {
+	response := newFakeResponse(http.StatusOK, "", http.Header{"X-Goog-Hash": {"crc32c=AAAAAQ==, md5=AAE="}})
+
+	attributes, err := ParseObjectAttributes(response)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, attributes.CRC32C, should.Equal, uint32(1))
+	should.So(t, attributes.MD5, should.Equal, []byte{0, 1})
+}
+func TestParseObjectAttributes_Minimal(t *testing.T) {
+	attributes, err := ParseObjectAttributes(newFakeResponse(http.StatusOK, "hello", nil))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, attributes, should.Equal, ObjectAttributes{Size: 5})
+}
+func TestParseObjectAttributes_Malformed(t *testing.T) {
+	assertMalformedAttributes(t, "X-Goog-Generation", "not-a-number")
+	assertMalformedAttributes(t, "X-Goog-Metageneration", "x")
+	assertMalformedAttributes(t, "X-Goog-Stored-Content-Length", "-")
+	assertMalformedAttributes(t, "X-Goog-Hash", "md5=***")
+	assertMalformedAttributes(t, "X-Goog-Hash", "crc32c=AAE=")
+	assertMalformedAttributes(t, "Last-Modified", "yesterday")
+}
+func assertMalformedAttributes(t *testing.T, name, value string) {
+	t.Helper()
+
+	attributes, err := ParseObjectAttributes(newFakeResponse(http.StatusOK, "", http.Header{name: {value}}))
+
+	should.So(t, errors.Is(err, ErrMalformedAttributes), should.BeTrue)
+	should.So(t, attributes, should.Equal, ObjectAttributes{})
+}
+func TestClientHead_Attributes(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, "", http.Header{
+		"X-Goog-Metageneration": {"7"},
+		"X-Goog-Meta-Origin":    {"ci"},
+	})}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	object, err := client.Head(WithResource("file.txt"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, object.Metageneration, should.Equal, int64(7))
+	should.So(t, object.Metadata["origin"], should.Equal, "ci")
+}
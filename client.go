@@ -0,0 +1,115 @@
+package gcs
+
+import (
+	"io"
+	"net/http"
+)
+
+// Client executes the requests built by NewRequest and interprets the responses. The default options provided
+// (e.g. WithCredentials, WithBucket, WithEndpoint) are applied to every request ahead of per-call options.
+type Client struct {
+	client   httpClient
+	defaults []Option
+}
+
+func NewClient(client httpClient, defaults ...Option) *Client {
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+
+	return &Client{client: client, defaults: defaults}
+}
+
+type Object struct {
+	Body  io.ReadCloser // only populated by Get; the caller is responsible for closing it
+	Range *ContentRange // only populated by Get when a range was requested (see GetWithRange)
+	ObjectAttributes
+}
+
+func (this *Client) Get(options ...Option) (Object, error) {
+	return this.do(GET, options)
+}
+func (this *Client) Head(options ...Option) (Object, error) {
+	return this.do(HEAD, options)
+}
+func (this *Client) Put(options ...Option) (Object, error) {
+	return this.do(PUT, options)
+}
+func (this *Client) Delete(options ...Option) error {
+	_, err := this.do(DELETE, options)
+	return err
+}
+
+// RangedDownload prepares a download of the object in concurrent ranges, see NewRangedDownload.
+func (this *Client) RangedDownload(options ...Option) (*RangedDownload, error) {
+	return NewRangedDownload(this.client, this.options(options)...)
+}
+
+// ResumableUpload prepares (but does not start) a resumable upload session, see NewResumableUpload.
+func (this *Client) ResumableUpload(options ...Option) (*ResumableUpload, error) {
+	return NewResumableUpload(this.client, this.options(options)...)
+}
+
+func (this *Client) do(method string, options []Option) (Object, error) {
+	request, err := NewRequest(method, this.options(options)...)
+	if err != nil {
+		return Object{}, err
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return Object{}, err
+	}
+
+	if err = ParseErrorResponse(response); err != nil {
+		drain(response)
+		return Object{}, err
+	}
+
+	result, err := newObject(response)
+	if err != nil {
+		drain(response)
+		return Object{}, err
+	}
+
+	if method == GET {
+		result.Body = response.Body
+	} else {
+		drain(response)
+	}
+
+	return result, nil
+}
+func (this *Client) options(options []Option) []Option {
+	return append(append([]Option{}, this.defaults...), options...)
+}
+func newObject(response *http.Response) (Object, error) {
+	attributes, err := ParseObjectAttributes(response)
+	if err != nil {
+		return Object{}, err
+	}
+
+	result := Object{ObjectAttributes: attributes}
+	if value := response.Header.Get(headerContentRange); len(value) > 0 {
+		contentRange, err := ParseContentRange(value)
+		if err != nil {
+			return Object{}, err
+		}
+
+		result.Range = &contentRange
+		if len(response.Header.Get(headerStoredContentLength)) == 0 && contentRange.Size >= 0 {
+			result.Size = contentRange.Size // rather than the length of the range
+		}
+	}
+
+	return result, nil
+}
+func drain(response *http.Response) {
+	_, _ = io.Copy(io.Discard, response.Body) // drain response body
+	_ = response.Body.Close()
+}
+
+const (
+	headerObjectGeneration    = "x-goog-generation"
+	headerStoredContentLength = "x-goog-stored-content-length"
+)
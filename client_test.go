@@ -0,0 +1,168 @@
+package gcs
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestClientGet(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, "hello", http.Header{
+		"Etag":              {`"etag"`},
+		"Content-Type":      {"text/plain"},
+		"X-Goog-Generation": {"1700000000000001"},
+	})}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	object, err := client.Get(WithResource("file.txt"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, fake.requests[0].Method, should.Equal, "GET")
+	should.So(t, fake.requests[0].URL.Path, should.Equal, "/bucket/file.txt")
+	should.So(t, object.ETag, should.Equal, `"etag"`)
+	should.So(t, object.ContentType, should.Equal, "text/plain")
+	should.So(t, object.Generation, should.Equal, int64(1700000000000001))
+	should.So(t, object.Size, should.Equal, int64(5))
+	body, _ := io.ReadAll(object.Body)
+	should.So(t, string(body), should.Equal, "hello")
+}
+func TestClientGet_Range(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusPartialContent, "llo", http.Header{
+		"Content-Range": {"bytes 2-4/5"},
+	})}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	object, err := client.Get(WithResource("file.txt"), GetWithRange(2, 3))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, fake.requests[0].Header.Get("Range"), should.Equal, "bytes=2-4")
+	should.So(t, *object.Range, should.Equal, ContentRange{First: 2, Last: 4, Size: 5})
+	should.So(t, object.Size, should.Equal, int64(5))
+	body, _ := io.ReadAll(object.Body)
+	should.So(t, string(body), should.Equal, "llo")
+}
+func TestClientGet_MalformedContentRange(t *testing.T) {
+	response := newFakeResponse(http.StatusPartialContent, "llo", http.Header{"Content-Range": {"bytes 2-4"}})
+	client := NewClient(&FakeHTTPClient{responses: []*http.Response{response}}, WithBucket("bucket"))
+
+	object, err := client.Get(WithResource("file.txt"), GetWithRange(2, 3))
+
+	should.So(t, errors.Is(err, ErrMalformedContentRange), should.BeTrue)
+	should.So(t, object.Body, should.BeNil)
+	should.So(t, response.Body.(*fakeBody).closed, should.BeTrue)
+}
+func TestClientHead_StoredContentLength(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, "", http.Header{
+		"X-Goog-Stored-Content-Length": {"1024"},
+	})}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	object, err := client.Head(WithResource("file.txt"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, fake.requests[0].Method, should.Equal, "HEAD")
+	should.So(t, object.Body, should.BeNil)
+	should.So(t, object.Size, should.Equal, int64(1024))
+}
+func TestClientPut(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, "", http.Header{
+		"X-Goog-Generation": {"42"},
+	})}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	object, err := client.Put(WithResource("file.txt"), PutWithContentString("hi"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, fake.requests[0].Method, should.Equal, "PUT")
+	should.So(t, object.Generation, should.Equal, int64(42))
+	should.So(t, object.Body, should.BeNil)
+}
+func TestClientDelete(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusNoContent, "", nil)}}
+	client := NewClient(fake, WithBucket("bucket"))
+
+	err := client.Delete(WithResource("file.txt"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, fake.requests[0].Method, should.Equal, "DELETE")
+}
+func TestClientInvalidRequest(t *testing.T) {
+	fake := &FakeHTTPClient{}
+	client := NewClient(fake)
+
+	_, err := client.Get(WithResource("file.txt"))
+
+	should.So(t, err, should.Equal, ErrBucketMissing)
+	should.So(t, len(fake.requests), should.Equal, 0)
+}
+func TestClientTransportFailure(t *testing.T) {
+	transportErr := errors.New("connection reset")
+	client := NewClient(&FakeHTTPClient{err: transportErr}, WithBucket("bucket"))
+
+	_, err := client.Get(WithResource("file.txt"))
+
+	should.So(t, err, should.Equal, transportErr)
+}
+func TestClientStatusErrors(t *testing.T) {
+	assertStatusError(t, http.StatusNotModified, ErrNotModified)
+	assertStatusError(t, http.StatusNotFound, ErrObjectNotFound)
+	assertStatusError(t, http.StatusPreconditionFailed, ErrPreconditionFailed)
+	assertStatusError(t, http.StatusTooManyRequests, ErrRateLimited)
+	assertStatusError(t, http.StatusRequestedRangeNotSatisfiable, ErrRangeNotSatisfiable)
+	assertStatusError(t, http.StatusInternalServerError, ErrUnexpectedStatus)
+}
+func assertStatusError(t *testing.T, statusCode int, expected error) {
+	t.Helper()
+	response := newFakeResponse(statusCode, "body", nil)
+	client := NewClient(&FakeHTTPClient{responses: []*http.Response{response}}, WithBucket("bucket"))
+
+	object, err := client.Get(WithResource("file.txt"))
+
+	should.So(t, errors.Is(err, expected), should.BeTrue)
+	should.So(t, object.Body, should.BeNil)
+	should.So(t, response.Body.(*fakeBody).closed, should.BeTrue)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+type FakeHTTPClient struct {
+	requests  []*http.Request
+	responses []*http.Response
+	err       error
+}
+
+func (this *FakeHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	this.requests = append(this.requests, request)
+	if this.err != nil {
+		return nil, this.err
+	}
+
+	response := this.responses[0]
+	if len(this.responses) > 1 {
+		this.responses = this.responses[1:]
+	}
+	return response, nil
+}
+
+func newFakeResponse(statusCode int, body string, headers http.Header) *http.Response {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &http.Response{
+		StatusCode:    statusCode,
+		Header:        headers,
+		Body:          &fakeBody{Reader: strings.NewReader(body)},
+		ContentLength: int64(len(body)),
+	}
+}
+
+type fakeBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (this *fakeBody) Close() error { this.closed = true; return nil }
@@ -2,6 +2,7 @@ package gcs
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -12,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 )
 
 func ParseCredentialsFromJSON(raw []byte, options ...ResolverOption) (Credentials, error) {
@@ -24,30 +26,60 @@ func ParseCredentialsFromJSON(raw []byte, options ...ResolverOption) (Credential
 		return Credentials{}, err
 	}
 
+	credentials, err := parseCredentials(parsed, raw, options...)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	credentials.Provenance.Type = parsed.Type
+	if len(credentials.Provenance.Principal) == 0 {
+		credentials.Provenance.Principal = credentials.AccessID
+	}
+	return credentials, nil
+}
+func parseCredentials(parsed clientSecrets, raw []byte, options ...ResolverOption) (Credentials, error) {
+	if parsed.Type == impersonatedServiceAccountType {
+		return parseImpersonatedCredentials(raw, options...)
+	}
+
+	resolver := newTokenResolver(options...)
+	if parsed.Type == externalAccountType {
+		return newExternalAccountCredentials(raw, resolver)
+	}
+
 	user := parsed.ClientIdentity()
-	if len(user.RefreshToken) == 0 {
+	if len(user.RefreshToken) == 0 && resolver.signed {
+		return newSelfSignedCredentials(parsed, resolver)
+	} else if len(user.RefreshToken) == 0 {
 		return NewCredentials(parsed.ServiceAccount())
 	}
 
-	resolver := newTokenResolver(options...)
+	if resolver.refresh {
+		return Credentials{TokenSource: NewCachingTokenSource(&identityTokenSource{resolver: resolver, identity: user})}, nil
+	}
+
 	accessToken, err := resolver.AccessToken(user)
 	if err != nil {
 		return Credentials{}, err
 	}
 
-	bearerToken := fmt.Sprintf("%s %s", accessToken.Type, accessToken.Value)
-	return Credentials{BearerToken: bearerToken}, nil
+	expiration := time.Now().Add(time.Duration(accessToken.Expiration) * time.Second)
+	return Credentials{BearerToken: accessToken.bearer(), Provenance: Provenance{Expiration: expiration}}, nil
 }
 
 /* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
 
 type clientSecrets struct {
+	Type string `json:"type"`
+
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	RefreshToken string `json:"refresh_token"`
 
 	ClientEmail   string `json:"client_email"`
+	PrivateKeyID  string `json:"private_key_id"`
 	PrivateKeyPEM string `json:"private_key"`
+	TokenURI      string `json:"token_uri"`
 }
 
 func unmarshalClientCredentials(raw []byte) (result clientSecrets, err error) {
@@ -72,9 +104,13 @@ func (this clientSecrets) ServiceAccount() (string, []byte) {
 
 type Credentials struct {
 	BearerToken string
+	TokenSource TokenSource // consulted as each request is built (when BearerToken is empty)
 
 	AccessID   string
 	PrivateKey PrivateKey
+	Signer     Signer // when provided, used in place of PrivateKey (e.g. NewIAMSigner or NewCryptoSigner)
+
+	Provenance Provenance
 }
 
 func NewCredentials(accessID string, privateKey []byte) (Credentials, error) {
@@ -85,6 +121,33 @@ func NewCredentials(accessID string, privateKey []byte) (Credentials, error) {
 	}
 }
 
+// bearerToken returns the value of the Authorization header, if any; empty values indicate signed requests.
+func (this Credentials) bearerToken(ctx context.Context) (string, error) {
+	if len(this.BearerToken) > 0 || this.TokenSource == nil {
+		return this.BearerToken, nil
+	} else if token, err := this.TokenSource.Token(ctx); err != nil {
+		return "", err
+	} else {
+		return token.bearer(), nil
+	}
+}
+
+// signer returns the Signer, if any, or one which uses the PrivateKey; an empty signature results when neither is
+// present.
+func (this Credentials) signer() Signer {
+	if this.Signer != nil {
+		return this.Signer
+	}
+	return privateKeySigner{key: this.PrivateKey}
+}
+
+func (this AccessToken) bearer() string {
+	if len(this.Type) == 0 {
+		return "Bearer " + this.Value
+	}
+	return fmt.Sprintf("%s %s", this.Type, this.Value)
+}
+
 /* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
 
 type PrivateKey struct {
@@ -125,6 +188,13 @@ func (this *PrivateKey) Sign(raw []byte) ([]byte, error) {
 	return rsa.SignPKCS1v15(this.random, this.inner, crypto.SHA256, sum[:])
 }
 
+// privateKeySigner adapts a PrivateKey held in process memory to the Signer interface.
+type privateKeySigner struct{ key PrivateKey }
+
+func (this privateKeySigner) Sign(_ context.Context, raw []byte) ([]byte, error) {
+	return this.key.Sign(raw)
+}
+
 var (
 	ErrMalformedPrivateKey   = errors.New("malformed private key")
 	ErrUnsupportedPrivateKey = errors.New("unsupported private key type")
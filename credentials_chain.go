@@ -0,0 +1,138 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CredentialProvider is a single source of credentials consulted, in order, by a CredentialsReader (see
+// CredentialOptions.Providers). Providers which find nothing to offer return an error wrapping ErrProviderSkipped
+// (describing why), such that the next provider is consulted; any other error ends the search.
+type CredentialProvider interface {
+	Name() string
+	Read(ctx context.Context, value string) (Credentials, error)
+}
+
+// CredentialsChainError describes each provider consulted, and why it was skipped, when no credentials were found. It
+// is returned in place of ErrCredentialsFailure, which it wraps (see errors.Is and errors.As).
+type CredentialsChainError struct {
+	Attempts []CredentialAttempt
+}
+type CredentialAttempt struct {
+	Provider string
+	Err      error
+}
+
+func (this *CredentialsChainError) Error() string {
+	builder := strings.Builder{}
+	builder.WriteString(ErrCredentialsFailure.Error())
+	for i, attempt := range this.Attempts {
+		if i == 0 {
+			builder.WriteString(": ")
+		} else {
+			builder.WriteString("; ")
+		}
+		_, _ = fmt.Fprintf(&builder, "%s (%s)", attempt.Provider, strings.TrimPrefix(attempt.Err.Error(), ErrProviderSkipped.Error()+": "))
+	}
+	return builder.String()
+}
+func (this *CredentialsChainError) Unwrap() error { return ErrCredentialsFailure }
+
+var ErrProviderSkipped = errors.New("credential provider skipped")
+
+func skipProvider(format string, values ...any) error {
+	return fmt.Errorf("%w: %s", ErrProviderSkipped, fmt.Sprintf(format, values...))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type providerSingleton struct{}
+
+// CredentialProviders offers the providers which make up the default chain (see Default), each of which uses the
+// environment, files, HTTP client, etc. of the CredentialsReader to which it is provided, as well as Func, which
+// adapts a custom provider.
+var CredentialProviders providerSingleton
+
+// ExplicitToken offers the OAuth access token provided to CredentialsReader.Read, if any.
+func (providerSingleton) ExplicitToken() CredentialProvider {
+	return readerProvider{name: "explicit token", read: (*defaultReader).readExplicitToken}
+}
+
+// AccessTokenEnvironment offers the OAuth access token found in GOOGLE_OAUTH_ACCESS_TOKEN.
+func (providerSingleton) AccessTokenEnvironment() CredentialProvider {
+	return readerProvider{name: "GOOGLE_OAUTH_ACCESS_TOKEN", read: (*defaultReader).readAccessTokenEnvironment}
+}
+
+// CredentialsEnvironment parses the base64-encoded JSON found in GOOGLE_CREDENTIALS.
+func (providerSingleton) CredentialsEnvironment() CredentialProvider {
+	return readerProvider{name: "GOOGLE_CREDENTIALS", read: (*defaultReader).readCredentialsEnvironment}
+}
+
+// CredentialsFile parses the JSON file named by GOOGLE_APPLICATION_CREDENTIALS.
+func (providerSingleton) CredentialsFile() CredentialProvider {
+	return readerProvider{name: "GOOGLE_APPLICATION_CREDENTIALS", read: (*defaultReader).readCredentialsFile}
+}
+
+// Vault reads from Vault's Google Cloud Secrets Engine (see CredentialOptions.VaultServer and VaultKey).
+func (providerSingleton) Vault() CredentialProvider {
+	return readerProvider{name: "Vault", read: (*defaultReader).readVault}
+}
+
+// WellKnownFile parses gcloud's application default credentials file.
+func (providerSingleton) WellKnownFile() CredentialProvider {
+	return readerProvider{name: "well-known file", read: (*defaultReader).readWellKnownFile}
+}
+
+// MetadataServer requests the access token of the attached service account from the GCE metadata server.
+func (providerSingleton) MetadataServer() CredentialProvider {
+	return readerProvider{name: "metadata server", read: (*defaultReader).readMetadataServer}
+}
+
+// Default returns the providers consulted when none are specified, in order.
+func (providerSingleton) Default() []CredentialProvider {
+	return []CredentialProvider{
+		CredentialProviders.ExplicitToken(),
+		CredentialProviders.AccessTokenEnvironment(),
+		CredentialProviders.CredentialsEnvironment(),
+		CredentialProviders.CredentialsFile(),
+		CredentialProviders.Vault(),
+		CredentialProviders.WellKnownFile(),
+		CredentialProviders.MetadataServer(),
+	}
+}
+
+// Func adapts a function to the CredentialProvider interface.
+func (providerSingleton) Func(name string, read func(ctx context.Context, value string) (Credentials, error)) CredentialProvider {
+	return funcProvider{name: name, read: read}
+}
+
+type funcProvider struct {
+	name string
+	read func(context.Context, string) (Credentials, error)
+}
+
+func (this funcProvider) Name() string { return this.name }
+func (this funcProvider) Read(ctx context.Context, value string) (Credentials, error) {
+	return this.read(ctx, value)
+}
+
+// readerProvider is bound to the reader to which it is provided (see NewCredentialsReader).
+type readerProvider struct {
+	name   string
+	read   func(*defaultReader, context.Context, string) (Credentials, error)
+	reader *defaultReader
+}
+
+func (this readerProvider) Name() string { return this.name }
+func (this readerProvider) Read(ctx context.Context, value string) (Credentials, error) {
+	if this.reader == nil {
+		return Credentials{}, skipProvider("only available to a CredentialsReader")
+	}
+	return this.read(this.reader, ctx, value)
+}
+func (this readerProvider) bind(reader *defaultReader) CredentialProvider {
+	this.reader = reader
+	return this
+}
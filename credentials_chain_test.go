@@ -0,0 +1,73 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestChain_Diagnostics(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{"HOME": "/home/user"}, CredentialOptions.MetadataTimeout(0))
+
+	_, err := reader.Read(context.Background(), "")
+
+	var failure *CredentialsChainError
+	should.So(t, errors.As(err, &failure), should.BeTrue)
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, len(failure.Attempts), should.Equal, 7)
+	should.So(t, errors.Is(failure.Attempts[0].Err, ErrProviderSkipped), should.BeTrue)
+	should.So(t, err.Error(), should.Equal, "unable to discover credentials: "+
+		"explicit token (no token provided); "+
+		"GOOGLE_OAUTH_ACCESS_TOKEN (not set); "+
+		"GOOGLE_CREDENTIALS (not set); "+
+		"GOOGLE_APPLICATION_CREDENTIALS (not set); "+
+		"Vault (VAULT_ADDR not set); "+
+		"well-known file ([/home/user/.config/gcloud/application_default_credentials.json] not found); "+
+		"metadata server (disabled)")
+}
+func TestChain_CustomOrder(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"GOOGLE_OAUTH_ACCESS_TOKEN": "environment-token",
+		"HOME":                      "/home/user",
+		"/home/user/.config/gcloud/application_default_credentials.json": string(sampleServiceAccountJSON),
+	}, CredentialOptions.Providers(CredentialProviders.WellKnownFile(), CredentialProviders.AccessTokenEnvironment()))
+
+	credentials, err := reader.Read(context.Background(), "explicit-token")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+}
+func TestChain_CustomProvider(t *testing.T) {
+	var received string
+	custom := CredentialProviders.Func("custom", func(_ context.Context, value string) (Credentials, error) {
+		received = value
+		return Credentials{BearerToken: "Bearer custom-token"}, nil
+	})
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.Providers(CredentialProviders.AccessTokenEnvironment(), custom))
+
+	credentials, err := reader.Read(context.Background(), "value")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.BearerToken, should.Equal, "Bearer custom-token")
+	should.So(t, received, should.Equal, "value")
+}
+func TestChain_FailureEndsSearch(t *testing.T) {
+	failure := errors.New("failure")
+	reader := newTestCredentialsReader(FakeEnvironment{"GOOGLE_OAUTH_ACCESS_TOKEN": "environment-token"},
+		CredentialOptions.Providers(
+			CredentialProviders.Func("failing", func(context.Context, string) (Credentials, error) { return Credentials{}, failure }),
+			CredentialProviders.AccessTokenEnvironment()))
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.Equal, failure)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+func TestChain_UnboundProvider(t *testing.T) {
+	_, err := CredentialProviders.ExplicitToken().Read(context.Background(), "token")
+
+	should.So(t, errors.Is(err, ErrProviderSkipped), should.BeTrue)
+}
@@ -0,0 +1,275 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// externalAccount describes Workload Identity Federation ("type": "external_account") credentials: a subject token
+// issued by another identity provider is exchanged at Google's Security Token Service for an access token which may,
+// in turn, be used to impersonate a service account.
+// https://google.aip.dev/auth/4117
+type externalAccount struct {
+	Audience         string `json:"audience"`
+	SubjectTokenType string `json:"subject_token_type"`
+	TokenURL         string `json:"token_url"`
+	ImpersonationURL string `json:"service_account_impersonation_url"`
+	Impersonation    struct {
+		Lifetime int `json:"token_lifetime_seconds"`
+	} `json:"service_account_impersonation"`
+	Source struct {
+		File          string            `json:"file"`
+		URL           string            `json:"url"`
+		Headers       map[string]string `json:"headers"`
+		EnvironmentID string            `json:"environment_id"`
+		Format        struct {
+			Type  string `json:"type"` // "text" (default) or "json"
+			Field string `json:"subject_token_field_name"`
+		} `json:"format"`
+		Executable struct {
+			Command    string `json:"command"`
+			Timeout    int    `json:"timeout_millis"`
+			OutputFile string `json:"output_file"`
+		} `json:"executable"`
+	} `json:"credential_source"`
+}
+
+func newExternalAccountCredentials(raw []byte, resolver *defaultResolver) (Credentials, error) {
+	var account externalAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return Credentials{}, ErrMalformedJSON
+	} else if len(account.Audience) == 0 || len(account.SubjectTokenType) == 0 {
+		return Credentials{}, ErrMalformedJSON
+	} else if len(account.Source.EnvironmentID) > 0 {
+		return Credentials{}, fmt.Errorf("%w [%s]", ErrUnsupportedCredentialSource, account.Source.EnvironmentID)
+	} else if len(account.Source.File) == 0 && len(account.Source.URL) == 0 && len(strings.TrimSpace(account.Source.Executable.Command)) == 0 {
+		return Credentials{}, ErrUnsupportedCredentialSource
+	}
+
+	if len(account.TokenURL) == 0 {
+		account.TokenURL = defaultSTSURL
+	}
+
+	var source TokenSource = &externalAccountTokenSource{
+		client:      resolver.client,
+		files:       resolver.fileReader,
+		environment: resolver.environmentReader,
+		account:     account,
+		scopes:      resolver.scopes,
+	}
+	if len(account.ImpersonationURL) > 0 {
+		source.(*externalAccountTokenSource).scopes = []string{cloudPlatformScope}
+		source = &impersonatedTokenSource{
+			client:   resolver.client,
+			base:     Credentials{TokenSource: source},
+			url:      account.ImpersonationURL,
+			scopes:   resolver.scopes,
+			lifetime: time.Duration(account.Impersonation.Lifetime) * time.Second,
+			now:      time.Now,
+		}
+	}
+
+	provenance := Provenance{Principal: impersonatedEmail(account.ImpersonationURL), Scopes: strings.Join(resolver.scopes, " ")}
+	return Credentials{TokenSource: NewCachingTokenSource(source), Provenance: provenance}, nil
+}
+
+type externalAccountTokenSource struct {
+	client      httpClient
+	files       fileReader
+	environment environmentReader
+	account     externalAccount
+	scopes      []string
+}
+
+// https://cloud.google.com/iam/docs/reference/sts/rest/v1/TopLevel/token
+func (this *externalAccountTokenSource) Token(ctx context.Context) (AccessToken, error) {
+	subjectToken, err := this.subjectToken(ctx)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	body := url.Values{
+		"grant_type":           {tokenExchangeGrantType},
+		"audience":             {this.account.Audience},
+		"scope":                {strings.Join(this.scopes, " ")},
+		"requested_token_type": {accessTokenType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {this.account.SubjectTokenType},
+	}
+	request, err := http.NewRequest("POST", this.account.TokenURL, strings.NewReader(body.Encode()))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	request.Header.Set(headerContentType, "application/x-www-form-urlencoded")
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	defer drain(response)
+	if err = ParseErrorResponse(response); err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %w", ErrFailedTokenRequest, err)
+	}
+
+	var token AccessToken
+	if err = json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return AccessToken{}, fmt.Errorf("unable to parse response body returned from the Security Token Service: %w", err)
+	}
+	return token, nil
+}
+
+func (this *externalAccountTokenSource) subjectToken(ctx context.Context) (string, error) {
+	source := this.account.Source
+	if len(source.Executable.Command) > 0 {
+		return this.executableToken(ctx)
+	}
+
+	var raw []byte
+	var err error
+	if len(source.File) > 0 {
+		if raw, err = this.files.ReadFile(source.File); err != nil {
+			return "", fmt.Errorf("unable to read subject token file [%s]: %w", source.File, err)
+		}
+	} else if raw, err = this.urlToken(ctx); err != nil {
+		return "", err
+	}
+
+	return this.formatToken(raw)
+}
+func (this *externalAccountTokenSource) urlToken(ctx context.Context) ([]byte, error) {
+	request, err := http.NewRequest("GET", this.account.Source.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse subject token URL: %w", err)
+	}
+
+	for name, value := range this.account.Source.Headers {
+		request.Header.Set(name, value)
+	}
+
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve subject token: %w", err)
+	}
+
+	defer drain(response)
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status retrieving subject token [%d]", response.StatusCode)
+	}
+	return io.ReadAll(response.Body)
+}
+func (this *externalAccountTokenSource) formatToken(raw []byte) (string, error) {
+	format := this.account.Source.Format
+	if format.Type != "json" {
+		return strings.TrimSpace(string(raw)), nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", fmt.Errorf("unable to parse subject token: %w", err)
+	} else if value, ok := fields[format.Field].(string); !ok || len(value) == 0 {
+		return "", fmt.Errorf("subject token field [%s] not found", format.Field)
+	} else {
+		return value, nil
+	}
+}
+
+// executableToken runs the configured command (only when explicitly allowed by the environment) and reads the
+// subject token from its output or, if still valid, from the output file of a previous run.
+// https://cloud.google.com/iam/docs/workload-identity-federation-with-other-providers#executable-sourced-credentials
+func (this *externalAccountTokenSource) executableToken(ctx context.Context) (string, error) {
+	if allowed, _ := this.environment.LookupEnv("GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES"); allowed != "1" {
+		return "", ErrExecutablesNotAllowed
+	}
+
+	executable := this.account.Source.Executable
+	if len(executable.OutputFile) > 0 {
+		if raw, err := this.files.ReadFile(executable.OutputFile); err == nil {
+			if token, err := parseExecutableResponse(raw); err == nil {
+				return token, nil // reuse the unexpired token of a previous run
+			}
+		}
+	}
+
+	timeout := time.Duration(executable.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultExecutableTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	arguments := strings.Fields(executable.Command)
+	command := exec.CommandContext(ctx, arguments[0], arguments[1:]...)
+	command.Env = append(os.Environ(),
+		"GOOGLE_EXTERNAL_ACCOUNT_AUDIENCE="+this.account.Audience,
+		"GOOGLE_EXTERNAL_ACCOUNT_TOKEN_TYPE="+this.account.SubjectTokenType,
+		"GOOGLE_EXTERNAL_ACCOUNT_INTERACTIVE=0",
+		"GOOGLE_EXTERNAL_ACCOUNT_IMPERSONATED_EMAIL="+impersonatedEmail(this.account.ImpersonationURL),
+		"GOOGLE_EXTERNAL_ACCOUNT_OUTPUT_FILE="+executable.OutputFile)
+
+	output, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to run subject token executable: %w", err)
+	}
+
+	return parseExecutableResponse(output)
+}
+func parseExecutableResponse(raw []byte) (string, error) {
+	var response struct {
+		Version        int    `json:"version"`
+		Success        bool   `json:"success"`
+		TokenType      string `json:"token_type"`
+		IDToken        string `json:"id_token"`
+		SAMLResponse   string `json:"saml_response"`
+		ExpirationTime int64  `json:"expiration_time"`
+		Code           string `json:"code"`
+		Message        string `json:"message"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(&response); err != nil {
+		return "", fmt.Errorf("unable to parse subject token executable output: %w", err)
+	} else if !response.Success {
+		return "", fmt.Errorf("subject token executable failed [%s]: %s", response.Code, response.Message)
+	} else if response.ExpirationTime > 0 && time.Now().Unix() >= response.ExpirationTime {
+		return "", fmt.Errorf("subject token executable returned an expired token")
+	} else if response.TokenType == saml2TokenType {
+		return response.SAMLResponse, nil
+	} else {
+		return response.IDToken, nil
+	}
+}
+
+// impersonatedEmail extracts the service account from ".../serviceAccounts/{email}:generateAccessToken".
+func impersonatedEmail(value string) string {
+	if index := strings.LastIndex(value, "/"); index >= 0 {
+		value = value[index+1:]
+	}
+	if index := strings.Index(value, ":"); index >= 0 {
+		value = value[:index]
+	}
+	return value
+}
+
+const (
+	externalAccountType      = "external_account"
+	defaultSTSURL            = "https://sts.googleapis.com/v1/token"
+	tokenExchangeGrantType   = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType          = "urn:ietf:params:oauth:token-type:access_token"
+	saml2TokenType           = "urn:ietf:params:oauth:token-type:saml2"
+	defaultExecutableTimeout = time.Second * 30
+)
+
+var (
+	ErrUnsupportedCredentialSource = errors.New("unsupported external account credential source")
+	ErrExecutablesNotAllowed       = errors.New("executable credential sources require GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES=1")
+)
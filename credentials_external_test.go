@@ -0,0 +1,159 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestExternalAccount_FileSource(t *testing.T) {
+	files := FakeEnvironment{"/var/run/token": "subject-token\n"}
+	service := &FakeExternalAccountService{}
+
+	credentials, err := ParseCredentialsFromJSON(externalAccountJSON(`"file": "/var/run/token"`, ""),
+		WithResolverClient(service), WithResolverFileReader(files), WithResolverScopes("scope1"))
+	should.So(t, err, should.BeNil)
+	bearerToken, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, bearerToken, should.Equal, "Bearer sts-token")
+	should.So(t, service.exchange.Get("grant_type"), should.Equal, "urn:ietf:params:oauth:grant-type:token-exchange")
+	should.So(t, service.exchange.Get("audience"), should.Equal, "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/provider")
+	should.So(t, service.exchange.Get("subject_token"), should.Equal, "subject-token")
+	should.So(t, service.exchange.Get("subject_token_type"), should.Equal, "urn:ietf:params:oauth:token-type:jwt")
+	should.So(t, service.exchange.Get("requested_token_type"), should.Equal, "urn:ietf:params:oauth:token-type:access_token")
+	should.So(t, service.exchange.Get("scope"), should.Equal, "scope1")
+}
+func TestExternalAccount_URLSourceWithJSONFormat(t *testing.T) {
+	service := &FakeExternalAccountService{}
+	source := `"url": "http://identity.local/token", "headers": {"Metadata": "true"}, "format": {"type": "json", "subject_token_field_name": "value"}`
+
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(source, ""), WithResolverClient(service))
+	bearerToken, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, bearerToken, should.Equal, "Bearer sts-token")
+	should.So(t, service.requests[0].Header.Get("Metadata"), should.Equal, "true")
+	should.So(t, service.exchange.Get("subject_token"), should.Equal, "url-subject-token")
+}
+func TestExternalAccount_Impersonation(t *testing.T) {
+	files := FakeEnvironment{"/var/run/token": "subject-token"}
+	service := &FakeExternalAccountService{}
+	impersonation := `"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/target@project.iam.gserviceaccount.com:generateAccessToken",
+		"service_account_impersonation": {"token_lifetime_seconds": 600},`
+
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(`"file": "/var/run/token"`, impersonation),
+		WithResolverClient(service), WithResolverFileReader(files), WithResolverScopes("scope1"))
+	bearerToken, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, bearerToken, should.Equal, "Bearer impersonated-token")
+	should.So(t, service.exchange.Get("scope"), should.Equal, "https://www.googleapis.com/auth/cloud-platform")
+	should.So(t, service.impersonation.Header.Get("Authorization"), should.Equal, "Bearer sts-token")
+	should.So(t, service.impersonationBody, should.Equal, `{"scope":["scope1"],"lifetime":"600s"}`)
+}
+func TestExternalAccount_ExchangeRejected(t *testing.T) {
+	files := FakeEnvironment{"/var/run/token": "subject-token"}
+	service := &FakeExternalAccountService{exchangeStatus: http.StatusBadRequest}
+
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(`"file": "/var/run/token"`, ""),
+		WithResolverClient(service), WithResolverFileReader(files))
+	_, err := credentials.bearerToken(context.Background())
+
+	should.So(t, errors.Is(err, ErrFailedTokenRequest), should.BeTrue)
+	should.So(t, strings.Contains(err.Error(), "invalid_grant"), should.BeTrue)
+}
+func TestExternalAccount_MissingSubjectTokenFile(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(`"file": "/var/run/token"`, ""),
+		WithResolverClient(&FakeExternalAccountService{}), WithResolverFileReader(FakeEnvironment{}))
+
+	_, err := credentials.bearerToken(context.Background())
+
+	should.So(t, errors.Is(err, os.ErrNotExist), should.BeTrue)
+}
+func TestExternalAccount_ExecutableSource(t *testing.T) {
+	environment := FakeEnvironment{"GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES": "1"}
+	service := &FakeExternalAccountService{}
+	command := `"executable": {"command": "echo {\"version\":1,\"success\":true,\"token_type\":\"urn:ietf:params:oauth:token-type:jwt\",\"id_token\":\"executable-token\"}"}`
+
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(command, ""),
+		WithResolverClient(service), WithResolverEnvironmentReader(environment))
+	_, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.exchange.Get("subject_token"), should.Equal, "executable-token")
+}
+func TestExternalAccount_ExecutablesNotAllowed(t *testing.T) {
+	t.Setenv("GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES", "1") // the process environment is not consulted
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(`"executable": {"command": "echo"}`, ""),
+		WithResolverClient(&FakeExternalAccountService{}), WithResolverEnvironmentReader(FakeEnvironment{}))
+
+	_, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.Equal, ErrExecutablesNotAllowed)
+}
+func TestExternalAccount_UnsupportedSource(t *testing.T) {
+	credentials, err := ParseCredentialsFromJSON(externalAccountJSON(`"environment_id": "aws1"`, ""))
+
+	should.So(t, errors.Is(err, ErrUnsupportedCredentialSource), should.BeTrue)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+func TestExternalAccount_BlankExecutableCommand(t *testing.T) {
+	credentials, err := ParseCredentialsFromJSON(externalAccountJSON(`"executable": {"command": " \t"}`, ""))
+
+	should.So(t, err, should.Equal, ErrUnsupportedCredentialSource)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+func externalAccountJSON(source, impersonation string) []byte {
+	return []byte(`{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url": "https://sts.googleapis.com/v1/token",
+		` + impersonation + `
+		"credential_source": {` + source + `}
+	}`)
+}
+
+// FakeExternalAccountService answers subject token, STS, and IAM Credentials requests.
+type FakeExternalAccountService struct {
+	requests          []*http.Request
+	exchange          url.Values
+	exchangeStatus    int
+	impersonation     *http.Request
+	impersonationBody string
+}
+
+func (this *FakeExternalAccountService) Do(request *http.Request) (*http.Response, error) {
+	this.requests = append(this.requests, request)
+	body := []byte{}
+	if request.Body != nil {
+		body, _ = io.ReadAll(request.Body)
+	}
+
+	switch request.URL.Host {
+	case "identity.local":
+		return newFakeResponse(http.StatusOK, `{"value": "url-subject-token"}`, nil), nil
+	case "sts.googleapis.com":
+		this.exchange, _ = url.ParseQuery(string(body))
+		if this.exchangeStatus > 0 {
+			return newFakeResponse(this.exchangeStatus, `{"error": "invalid_grant", "error_description": "bad token"}`, nil), nil
+		}
+		return newFakeResponse(http.StatusOK, `{"access_token": "sts-token", "token_type": "Bearer", "expires_in": 3600}`, nil), nil
+	default:
+		this.impersonation, this.impersonationBody = request, string(body)
+		raw, _ := json.Marshal(map[string]string{"accessToken": "impersonated-token", "expireTime": "2099-01-01T00:00:00Z"})
+		return newFakeResponse(http.StatusOK, string(raw), nil), nil
+	}
+}
@@ -0,0 +1,115 @@
+package gcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// resolveMetadataToken probes the metadata server available to workloads running on GCE, GKE (Workload Identity),
+// Cloud Run, etc. The probe is bounded by a short timeout such that it fails quickly everywhere else; once it has
+// failed to reach a metadata server, it isn't attempted again by the same reader.
+// https://cloud.google.com/compute/docs/access/authenticate-workloads#applications
+func (this *defaultReader) resolveMetadataToken(ctx context.Context) (Credentials, error) {
+	if this.metadataAbsent.Load() {
+		return Credentials{}, errMetadataUnavailable
+	}
+
+	host := defaultMetadataHost
+	if read, found := this.environmentReader.LookupEnv("GCE_METADATA_HOST"); found && len(read) > 0 {
+		host = read
+	}
+
+	source := NewCachingTokenSource(&metadataTokenSource{client: this.client, host: host})
+
+	probe, cancel := context.WithTimeout(ctx, this.metadataTimeout)
+	defer cancel()
+
+	token, err := source.Token(probe)
+	if ctx.Err() != nil {
+		return Credentials{}, ctx.Err()
+	} else if errors.Is(err, errMetadataUnavailable) {
+		this.metadataAbsent.Store(true) // i.e. not running on GCP
+		return Credentials{}, err
+	} else if err != nil {
+		return Credentials{}, err
+	}
+
+	provenance := Provenance{
+		Location:   "http://" + host,
+		Principal:  this.metadataEmail(ctx, host),
+		Expiration: time.Now().Add(time.Duration(token.Expiration) * time.Second),
+	}
+	return Credentials{TokenSource: source, Provenance: provenance}, nil
+}
+
+// metadataEmail returns the email of the attached service account, if available, such that it may be recorded.
+func (this *defaultReader) metadataEmail(ctx context.Context, host string) string {
+	ctx, cancel := context.WithTimeout(ctx, this.metadataTimeout)
+	defer cancel()
+
+	request, _ := http.NewRequest("GET", "http://"+host+metadataEmailPath, nil)
+	request.Header.Set(headerMetadataFlavor, metadataFlavor)
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return ""
+	}
+
+	defer drain(response)
+	if response.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	raw, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return strings.TrimSpace(string(raw))
+}
+
+type metadataTokenSource struct {
+	client httpClient
+	host   string
+}
+
+func (this *metadataTokenSource) Token(ctx context.Context) (AccessToken, error) {
+	request, err := http.NewRequest("GET", "http://"+this.host+metadataTokenPath, nil)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("unable to parse value specified in GCE_METADATA_HOST: %w", err)
+	}
+
+	request.Header.Set(headerMetadataFlavor, metadataFlavor)
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %w", errMetadataUnavailable, err)
+	}
+
+	defer func() { _ = response.Body.Close() }()
+	if response.Header.Get(headerMetadataFlavor) != metadataFlavor {
+		// something answered, but it wasn't a metadata server
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return AccessToken{}, errMetadataUnavailable
+	} else if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return AccessToken{}, fmt.Errorf("unexpected status from the metadata server [%d]", response.StatusCode)
+	}
+
+	var token AccessToken
+	if err = json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return AccessToken{}, fmt.Errorf("unable to parse response body returned from the metadata server: %w", err)
+	}
+
+	return token, nil
+}
+
+const (
+	defaultMetadataHost  = "169.254.169.254"
+	metadataTokenPath    = "/computeMetadata/v1/instance/service-accounts/default/token"
+	metadataEmailPath    = "/computeMetadata/v1/instance/service-accounts/default/email"
+	headerMetadataFlavor = "Metadata-Flavor"
+	metadataFlavor       = "Google"
+)
+
+var errMetadataUnavailable = errors.New("metadata server unavailable")
@@ -0,0 +1,82 @@
+package gcs
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Provenance describes where Credentials came from (see CredentialsReader) and whom they identify, such that
+// authentication as an unexpected identity may be diagnosed. Principal, Expiration, and Scopes are best-effort, being
+// recorded only where known as the credentials are read: Expiration describes the access token obtained at that time
+// (e.g. from Vault or the metadata server) rather than the tokens a TokenSource obtains later, and Scopes are those
+// requested for impersonated and external account credentials.
+type Provenance struct {
+	Source     string    // the CredentialProvider, e.g. "GOOGLE_APPLICATION_CREDENTIALS" or "metadata server"
+	Location   string    // the environment variable, file, or URL from which the credentials were read
+	Type       string    // the "type" of JSON credentials, e.g. "service_account" or "authorized_user"
+	Principal  string    // the email of the service account, when known
+	Expiration time.Time // of the BearerToken, when known
+	Scopes     string    // space-separated, as requested for access tokens minted on behalf of the principal
+	Lease      string    // the Vault lease of a generated service account key (see LeasedCredentialsReader)
+}
+
+// String describes the credentials without revealing the bearer token or key material.
+func (this Credentials) String() string {
+	builder := strings.Builder{}
+	builder.WriteString("gcs.Credentials{")
+	for i, attribute := range this.attributes() {
+		if i > 0 {
+			builder.WriteString(" ")
+		}
+		_, _ = fmt.Fprintf(&builder, "%s=%s", attribute.Key, attribute.Value)
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+func (this Credentials) GoString() string { return this.String() }
+
+// LogValue describes the credentials to log/slog without revealing the bearer token or key material.
+func (this Credentials) LogValue() slog.Value {
+	return slog.GroupValue(this.attributes()...)
+}
+func (this Credentials) attributes() (attributes []slog.Attr) {
+	appendIfAny := func(key, value string) {
+		if len(value) > 0 {
+			attributes = append(attributes, slog.String(key, value))
+		}
+	}
+
+	provenance := this.Provenance
+	appendIfAny("source", provenance.Source)
+	appendIfAny("location", provenance.Location)
+	appendIfAny("type", provenance.Type)
+	appendIfAny("principal", provenance.Principal)
+	appendIfAny("access_id", this.AccessID)
+	if !provenance.Expiration.IsZero() {
+		appendIfAny("expiration", provenance.Expiration.UTC().Format(time.RFC3339))
+	}
+	appendIfAny("scopes", provenance.Scopes)
+
+	if len(this.BearerToken) > 0 {
+		appendIfAny("bearer_token", redacted)
+	}
+	if this.TokenSource != nil {
+		appendIfAny("token_source", fmt.Sprintf("%T", this.TokenSource))
+	}
+	if this.PrivateKey.inner != nil {
+		appendIfAny("private_key", redacted)
+	}
+	if this.Signer != nil {
+		appendIfAny("signer", fmt.Sprintf("%T", this.Signer))
+	}
+
+	return attributes
+}
+
+// String ensures that key material isn't revealed when a PrivateKey is formatted.
+func (this PrivateKey) String() string   { return redacted }
+func (this PrivateKey) GoString() string { return redacted }
+
+const redacted = "[REDACTED]"
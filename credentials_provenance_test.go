@@ -0,0 +1,125 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestProvenance_CredentialsFile(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"GOOGLE_APPLICATION_CREDENTIALS": "/path/to/key.json",
+		"/path/to/key.json":              string(sampleServiceAccountJSON),
+	})
+
+	credentials, _ := reader.Read(context.Background(), "")
+
+	should.So(t, credentials.Provenance, should.Equal, Provenance{
+		Source:    "GOOGLE_APPLICATION_CREDENTIALS",
+		Location:  "/path/to/key.json",
+		Type:      "service_account",
+		Principal: "sample-key@project-id-here.iam.gserviceaccount.com",
+	})
+}
+func TestProvenance_AccessTokenEnvironment(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{"GOOGLE_OAUTH_ACCESS_TOKEN": "token"})
+
+	credentials, _ := reader.Read(context.Background(), "")
+
+	should.So(t, credentials.Provenance, should.Equal, Provenance{Source: "GOOGLE_OAUTH_ACCESS_TOKEN", Location: "GOOGLE_OAUTH_ACCESS_TOKEN"})
+}
+func TestProvenance_CustomProviderRetained(t *testing.T) {
+	custom := CredentialProviders.Func("custom", func(context.Context, string) (Credentials, error) {
+		return Credentials{BearerToken: "Bearer token", Provenance: Provenance{Source: "secrets manager"}}, nil
+	})
+	reader := newTestCredentialsReader(FakeEnvironment{}, CredentialOptions.Providers(custom))
+
+	credentials, _ := reader.Read(context.Background(), "")
+
+	should.So(t, credentials.Provenance.Source, should.Equal, "secrets manager")
+}
+func TestProvenance_MetadataServer(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusOK, metadataFlavor)
+	defer server.Close()
+	host := server.Listener.Addr().String()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": host})
+
+	credentials, _ := reader.Read(context.Background(), "")
+	expiration := credentials.Provenance.Expiration
+	credentials.Provenance.Expiration = time.Time{}
+
+	should.So(t, expiration.After(time.Now().Add(time.Minute*59)), should.BeTrue) // expires_in: 3599
+	should.So(t, credentials.Provenance, should.Equal, Provenance{
+		Source:    "metadata server",
+		Location:  "http://" + host,
+		Principal: "attached@project.iam.gserviceaccount.com",
+	})
+}
+func TestProvenance_Vault(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL+"/", "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/token"))
+
+	credentials, _ := reader.Read(context.Background(), "")
+
+	should.So(t, credentials.Provenance, should.Equal, Provenance{
+		Source:     "Vault",
+		Location:   server.URL + "/v1/gcp/roleset/name/token",
+		Expiration: time.Unix(1700000000, 0),
+	})
+}
+func TestProvenance_ExternalAccountScopes(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(externalAccountJSON(`"file": "/var/run/token"`, ""),
+		WithResolverScopes("scope1", "scope2"))
+
+	should.So(t, credentials.Provenance, should.Equal, Provenance{Type: "external_account", Scopes: "scope1 scope2"})
+}
+func TestProvenance_ImpersonatedScopes(t *testing.T) {
+	credentials, _ := NewImpersonatedCredentials(Credentials{BearerToken: "Bearer base-token"},
+		"target@project.iam.gserviceaccount.com", WithResolverScopes("scope1", "scope2"))
+
+	should.So(t, credentials.Provenance.Scopes, should.Equal, "scope1 scope2")
+}
+func TestCredentialsComparable(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	copied := credentials
+
+	should.So(t, copied == credentials, should.BeTrue)
+	should.So(t, Credentials{BearerToken: "Bearer token"} == Credentials{}, should.BeFalse)
+}
+
+func TestRedaction_String(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	credentials.BearerToken = "Bearer secret-token"
+	credentials.Provenance.Expiration = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	credentials.Provenance.Scopes = "scope1 scope2"
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v"} {
+		formatted := fmt.Sprintf(format, credentials)
+		should.So(t, formatted, should.Equal, "gcs.Credentials{type=service_account "+
+			"principal=sample-key@project-id-here.iam.gserviceaccount.com access_id=sample-key@project-id-here.iam.gserviceaccount.com "+
+			"expiration=2030-01-02T03:04:05Z scopes=scope1 scope2 bearer_token=[REDACTED] private_key=[REDACTED]}")
+	}
+	should.So(t, fmt.Sprintf("%+v", credentials.PrivateKey), should.Equal, "[REDACTED]")
+}
+func TestRedaction_LogValue(t *testing.T) {
+	credentials := Credentials{
+		BearerToken: "Bearer secret-token",
+		Provenance:  Provenance{Source: "explicit token"},
+	}
+	buffer := bytes.NewBuffer(nil)
+	logger := slog.New(slog.NewJSONHandler(buffer, nil))
+
+	logger.Info("authenticated", "credentials", credentials)
+
+	should.So(t, strings.Contains(buffer.String(), `"credentials":{"source":"explicit token","bearer_token":"[REDACTED]"}`), should.BeTrue)
+	should.So(t, strings.Contains(buffer.String(), "secret-token"), should.BeFalse)
+}
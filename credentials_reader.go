@@ -3,19 +3,30 @@ package gcs
 import (
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type CredentialsReader interface {
 	Read(context.Context, string) (Credentials, error)
 }
+
+// LeasedCredentialsReader is implemented by the CredentialsReader returned from NewCredentialsReader, i.e.
+// reader.(gcs.LeasedCredentialsReader). The io.Closer returned alongside the credentials belongs to the caller and
+// releases anything held on their behalf, e.g. revoking the Vault lease of a generated service account key (which Read
+// leaves in place), after which the credentials should no longer be used. It is never nil when the credentials are read
+// successfully and may be closed more than once.
+type LeasedCredentialsReader interface {
+	ReadLeased(context.Context, string) (Credentials, io.Closer, error)
+}
 type environmentReader interface {
 	LookupEnv(string) (string, bool)
 }
@@ -23,19 +34,40 @@ type fileReader interface {
 	ReadFile(string) ([]byte, error)
 }
 
+// ErrCredentialsFailure is wrapped by the *CredentialsChainError returned by the CredentialsReader from
+// NewCredentialsReader when no provider offers credentials. The error is no longer the sentinel itself, so compare it
+// using errors.Is(err, ErrCredentialsFailure) rather than ==.
 var ErrCredentialsFailure = errors.New("unable to discover credentials")
 
+const wellKnownFilename = "application_default_credentials.json"
+
 func NewCredentialsReader(options ...credentialOption) CredentialsReader {
 	var config credentialConfig
 	CredentialOptions.apply(options...)(&config)
-	return &defaultReader{
+	reader := &defaultReader{
 		client:            config.client,
 		fileReader:        config.fileReader,
 		environmentReader: config.environmentReader,
 		vaultAddress:      config.vaultAddress,
 		vaultToken:        config.vaultToken,
 		vaultKey:          config.vaultKey,
+		vaultNamespace:    config.vaultNamespace,
+		vaultLogin:        config.vaultLogin,
+		vaultRenew:        config.vaultRenew,
+		vaultSession:      &vaultSession{now: time.Now},
+		refresh:           config.refresh,
+		resolverOptions:   config.resolverOptions,
+		metadataTimeout:   config.metadataTimeout,
+	}
+
+	for _, provider := range config.providers {
+		if builtin, ok := provider.(readerProvider); ok {
+			provider = builtin.bind(reader)
+		}
+		reader.providers = append(reader.providers, provider)
 	}
+
+	return reader
 }
 
 type defaultReader struct {
@@ -45,104 +77,153 @@ type defaultReader struct {
 	vaultAddress      string
 	vaultToken        string
 	vaultKey          string
+	vaultNamespace    string
+	vaultLogin        vaultLogin
+	vaultRenew        bool
+	vaultSession      *vaultSession
+	refresh           bool
+	resolverOptions   []ResolverOption
+	metadataTimeout   time.Duration
+	metadataAbsent    atomic.Bool
+	providers         []CredentialProvider
 }
 
 func (this *defaultReader) Read(ctx context.Context, value string) (Credentials, error) {
+	failure := &CredentialsChainError{}
+	for _, provider := range this.providers {
+		credentials, err := provider.Read(ctx, value)
+		if err == nil {
+			if len(credentials.Provenance.Source) == 0 {
+				credentials.Provenance.Source = provider.Name()
+			}
+			return credentials, nil
+		} else if !errors.Is(err, ErrProviderSkipped) {
+			return Credentials{}, err
+		}
+		failure.Attempts = append(failure.Attempts, CredentialAttempt{Provider: provider.Name(), Err: err})
+	}
+
+	return Credentials{}, failure
+}
+
+func (this *defaultReader) ReadLeased(ctx context.Context, value string) (Credentials, io.Closer, error) {
+	credentials, err := this.Read(ctx, value)
+	if err != nil {
+		return Credentials{}, nil, err
+	}
+
+	return credentials, &vaultLease{reader: this, leaseID: credentials.Provenance.Lease}, nil
+}
+
+func (this *defaultReader) readExplicitToken(_ context.Context, value string) (Credentials, error) {
 	if value = sanitizeToken(value); len(value) > 0 {
 		return Credentials{BearerToken: value}, nil // short-lived, OAuth access token provided by caller
 	}
-
+	return Credentials{}, skipProvider("no token provided")
+}
+func (this *defaultReader) readAccessTokenEnvironment(_ context.Context, _ string) (Credentials, error) {
 	if read, found := this.environmentReader.LookupEnv("GOOGLE_OAUTH_ACCESS_TOKEN"); found {
-		return Credentials{BearerToken: sanitizeToken(read)}, nil // short-lived, OAuth access token (well-known environment variable)
+		provenance := Provenance{Location: "GOOGLE_OAUTH_ACCESS_TOKEN"}
+		return Credentials{BearerToken: sanitizeToken(read), Provenance: provenance}, nil // short-lived, OAuth access token (well-known environment variable)
 	}
-
-	if read, found := this.environmentReader.LookupEnv("GOOGLE_CREDENTIALS"); found {
-		if raw, err := base64.StdEncoding.DecodeString(read); err != nil {
-			return Credentials{}, fmt.Errorf("unable to base64 decode value from environment variable [GOOGLE_CREDENTIALS]: %w", err)
-		} else {
-			// base64-encoded representation of GOOGLE_APPLICATION_CREDENTIALS file (which itself is JSON encoded). Typically used by CI/CD pipelines and Terraform
-			return ParseCredentialsFromJSON(raw, WithResolverClient(this.client), WithResolverContext(ctx))
-		}
+	return Credentials{}, skipProvider("not set")
+}
+func (this *defaultReader) readCredentialsEnvironment(ctx context.Context, _ string) (Credentials, error) {
+	read, found := this.environmentReader.LookupEnv("GOOGLE_CREDENTIALS")
+	if !found {
+		return Credentials{}, skipProvider("not set")
 	}
 
-	if read, found := this.environmentReader.LookupEnv("GOOGLE_APPLICATION_CREDENTIALS"); found {
-		if raw, err := this.fileReader.ReadFile(read); err != nil {
-			return Credentials{}, fmt.Errorf("unable to read file specified in [GOOGLE_APPLICATION_CREDENTIALS]: %w", err)
-		} else {
-			// path to file containing long-lived, JSON-encoded Service Account Key.
-			return ParseCredentialsFromJSON(raw, WithResolverClient(this.client), WithResolverContext(ctx))
-		}
+	if raw, err := base64.StdEncoding.DecodeString(read); err != nil {
+		return Credentials{}, fmt.Errorf("unable to base64 decode value from environment variable [GOOGLE_CREDENTIALS]: %w", err)
+	} else {
+		// base64-encoded representation of GOOGLE_APPLICATION_CREDENTIALS file (which itself is JSON encoded). Typically used by CI/CD pipelines and Terraform
+		return this.parseJSON(ctx, raw, "GOOGLE_CREDENTIALS")
 	}
-
-	if len(this.vaultAddress) > 0 && len(this.vaultToken) > 0 && len(this.vaultKey) > 0 {
-		return this.resolveGoogleAccessToken(ctx) // use Vault's Google Cloud Secrets Engine to generate a short-lived, OAuth access token
+}
+func (this *defaultReader) readCredentialsFile(ctx context.Context, _ string) (Credentials, error) {
+	read, found := this.environmentReader.LookupEnv("GOOGLE_APPLICATION_CREDENTIALS")
+	if !found {
+		return Credentials{}, skipProvider("not set")
 	}
 
-	return Credentials{}, ErrCredentialsFailure
-
+	if raw, err := this.fileReader.ReadFile(read); err != nil {
+		return Credentials{}, fmt.Errorf("unable to read file specified in [GOOGLE_APPLICATION_CREDENTIALS]: %w", err)
+	} else {
+		// path to file containing long-lived, JSON-encoded Service Account Key.
+		return this.parseJSON(ctx, raw, read)
+	}
 }
-func (this *defaultReader) resolveGoogleAccessToken(ctx context.Context) (Credentials, error) {
-	parsed, err := url.Parse(this.vaultAddress)
-	if err != nil {
-		return Credentials{}, fmt.Errorf("unable to parse value specified in VAULT_ADDR: %w", err)
-	} else if len(parsed.Scheme) == 0 || len(parsed.Host) == 0 {
-		return Credentials{}, fmt.Errorf("unable to parse value specified in VAULT_ADDR: the format must be https://domain:port")
+func (this *defaultReader) readVault(ctx context.Context, _ string) (Credentials, error) {
+	if len(this.vaultAddress) == 0 {
+		return Credentials{}, skipProvider("VAULT_ADDR not set")
+	} else if len(this.vaultKey) == 0 {
+		return Credentials{}, skipProvider("VAULT_KEY not set")
+	} else if len(this.vaultToken) == 0 && len(this.vaultLogin.method) == 0 {
+		return Credentials{}, skipProvider("neither VAULT_TOKEN nor a login method configured")
 	}
 
-	request, _ := http.NewRequest("GET", parsed.Scheme+"://"+parsed.Host+"/v1/"+this.vaultKey, nil)
-	request.Header["X-Vault-Token"] = []string{this.vaultToken}
-
-	response, err := this.client.Do(request.WithContext(ctx))
-	if errors.Is(err, context.Canceled) {
-		return Credentials{}, context.Canceled
-	} else if errors.Is(err, context.DeadlineExceeded) {
-		return Credentials{}, context.DeadlineExceeded
-	} else if err != nil {
-		return Credentials{}, fmt.Errorf("unable to connect to the configured Vault server: %w", err)
+	return this.resolveGoogleAccessToken(ctx) // use Vault's Google Cloud Secrets Engine to generate a short-lived, OAuth access token
+}
+func (this *defaultReader) readWellKnownFile(ctx context.Context, _ string) (Credentials, error) {
+	path := this.wellKnownFile()
+	if len(path) == 0 {
+		return Credentials{}, skipProvider("no home directory")
 	}
 
-	defer func() { _ = response.Body.Close() }()
-	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusBadRequest {
-		_, _ = io.Copy(io.Discard, response.Body) // drain response body
-		return Credentials{}, fmt.Errorf("the Vault token provided did not have permission to read from [%s]", this.vaultKey)
-	} else if response.StatusCode != http.StatusOK {
-		_, _ = io.Copy(io.Discard, response.Body) // drain response body
-		return Credentials{}, fmt.Errorf("unexpected status from the Vault server [%d]", response.StatusCode)
+	if raw, err := this.fileReader.ReadFile(path); err == nil {
+		// written by "gcloud auth application-default login", typically on a developer's workstation
+		return this.parseJSON(ctx, raw, path)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		return Credentials{}, skipProvider("[%s] not found", path)
+	} else {
+		return Credentials{}, fmt.Errorf("unable to read well-known credentials file [%s]: %w", path, err)
 	}
-
-	if strings.ToLower(response.Header.Get("Content-Type")) != "application/json" {
-		_, _ = io.Copy(io.Discard, response.Body) // drain response body
-		return Credentials{}, fmt.Errorf("unknown Content-Type from the Vault server [%s]", response.Header.Get("Content-Type"))
+}
+func (this *defaultReader) readMetadataServer(ctx context.Context, _ string) (Credentials, error) {
+	if this.metadataTimeout <= 0 {
+		return Credentials{}, skipProvider("disabled")
 	}
 
-	raw, err := io.ReadAll(response.Body)
-	if errors.Is(err, context.Canceled) {
-		return Credentials{}, context.Canceled
-	} else if errors.Is(err, context.DeadlineExceeded) {
-		return Credentials{}, context.DeadlineExceeded
-	} else if err != nil {
-		return Credentials{}, fmt.Errorf("unable to read response from the Vault server: %w", err)
-	} else if len(raw) == 0 {
-		return Credentials{}, fmt.Errorf("zero-length response returned from the Vault server")
+	credentials, err := this.resolveMetadataToken(ctx)
+	if err != nil && ctx.Err() == nil {
+		return Credentials{}, skipProvider("%s", err) // e.g. not running on GCP, or no service account is attached
 	}
+	return credentials, err // short-lived, OAuth access token of the workload's attached service account
+}
 
-	body := struct {
-		Data struct {
-			Token string `json:"token"`
-		} `json:"data"`
-	}{}
+// wellKnownFile returns the path of gcloud's application default credentials file (which may not exist).
+func (this *defaultReader) wellKnownFile() string {
+	if directory, found := this.environmentReader.LookupEnv("CLOUDSDK_CONFIG"); found && len(directory) > 0 {
+		return filepath.Join(directory, wellKnownFilename)
+	} else if directory, found = this.environmentReader.LookupEnv("APPDATA"); found && len(directory) > 0 && runtime.GOOS == "windows" {
+		return filepath.Join(directory, "gcloud", wellKnownFilename)
+	} else if directory, found = this.environmentReader.LookupEnv("HOME"); found && len(directory) > 0 {
+		return filepath.Join(directory, ".config", "gcloud", wellKnownFilename)
+	} else {
+		return ""
+	}
+}
 
-	err = json.Unmarshal(raw, &body)
+// parseJSON parses credentials read from the location provided (an environment variable or file).
+func (this *defaultReader) parseJSON(ctx context.Context, raw []byte, location string) (Credentials, error) {
+	credentials, err := ParseCredentialsFromJSON(raw, this.parseOptions(ctx)...)
 	if err != nil {
-		return Credentials{}, fmt.Errorf("unable to parse response body returned from the Vault server: %w", err)
+		return Credentials{}, err
 	}
 
-	accessToken := strings.Trim(body.Data.Token, ". ") // eliminate spaces and periods from the end (and beginning)
-	if len(accessToken) == 0 {
-		return Credentials{}, fmt.Errorf("no access token was returned from the Vault server: %w", err)
+	credentials.Provenance.Location = location
+	return credentials, nil
+}
+func (this *defaultReader) parseOptions(ctx context.Context) []ResolverOption {
+	options := []ResolverOption{
+		WithResolverClient(this.client),
+		WithResolverContext(ctx),
+		WithResolverRefresh(this.refresh),
+		WithResolverFileReader(this.fileReader),
+		WithResolverEnvironmentReader(this.environmentReader),
 	}
-
-	return Credentials{BearerToken: "Bearer " + accessToken}, nil
+	return append(options, this.resolverOptions...)
 }
 func sanitizeToken(value string) string {
 	if value = strings.TrimSpace(value); len(value) == 0 {
@@ -163,6 +244,13 @@ type credentialConfig struct {
 	vaultAddress      string
 	vaultToken        string
 	vaultKey          string
+	vaultNamespace    string
+	vaultLogin        vaultLogin
+	vaultRenew        bool
+	refresh           bool
+	resolverOptions   []ResolverOption
+	metadataTimeout   time.Duration
+	providers         []CredentialProvider
 }
 type credentialSingleton struct{}
 type credentialOption func(*credentialConfig)
@@ -181,14 +269,82 @@ func (credentialSingleton) EnvironmentReader(value environmentReader) credential
 func (credentialSingleton) VaultServer(address, token string) credentialOption {
 	return func(this *credentialConfig) { this.vaultAddress = address; this.vaultToken = token }
 }
+
+// VaultKey is the path of the secret read from Vault, e.g. "gcp/roleset/:name/token" for a short-lived access token.
+// Paths which generate a service account key (e.g. "gcp/roleset/:name/key") produce a new key, and a new lease, with
+// each Read; the lease remains until it expires in Vault unless the credentials are read using ReadLeased (see
+// LeasedCredentialsReader) and closed once they are no longer needed.
 func (credentialSingleton) VaultKey(value string) credentialOption {
 	return func(this *credentialConfig) { this.vaultKey = strings.TrimLeft(value, "/") }
 }
+
+// VaultNamespace is sent as X-Vault-Namespace with each request to the Vault server (Vault Enterprise / HCP Vault).
+func (credentialSingleton) VaultNamespace(value string) credentialOption {
+	return func(this *credentialConfig) { this.vaultNamespace = strings.Trim(value, "/") }
+}
+
+// VaultAppRole logs in to Vault using the AppRole auth method rather than relying upon a Vault token (VAULT_TOKEN).
+func (credentialSingleton) VaultAppRole(roleID, secretID string) credentialOption {
+	return func(this *credentialConfig) {
+		this.vaultLogin.method, this.vaultLogin.roleID, this.vaultLogin.secretID = vaultAppRole, roleID, secretID
+	}
+}
+
+// VaultKubernetes logs in to Vault using the Kubernetes auth method and the pod's service account token, read from
+// the path provided (or, when empty, the default path at which the token is mounted).
+func (credentialSingleton) VaultKubernetes(role, jwtPath string) credentialOption {
+	if len(jwtPath) == 0 {
+		jwtPath = defaultKubernetesJWTPath
+	}
+	return func(this *credentialConfig) {
+		this.vaultLogin.method, this.vaultLogin.role, this.vaultLogin.jwtPath = vaultKubernetes, role, jwtPath
+	}
+}
+
+// VaultAuthMount sets the path at which the auth method of VaultAppRole or VaultKubernetes is mounted, when it isn't
+// the name of the method itself.
+func (credentialSingleton) VaultAuthMount(value string) credentialOption {
+	return func(this *credentialConfig) { this.vaultLogin.mount = strings.Trim(value, "/") }
+}
+
+// VaultRenew causes the lease of the Vault token provided (see VaultServer) to be renewed as it is first used and
+// again before it expires. Tokens obtained by logging in are always renewed, where permitted, or else replaced.
+func (credentialSingleton) VaultRenew(value bool) credentialOption {
+	return func(this *credentialConfig) { this.vaultRenew = value }
+}
+
+// Refresh causes credentials based upon a refresh token (e.g. "authorized_user" JSON) to resolve and cache their
+// access token as requests are built, rather than resolving a single access token which expires after an hour.
+func (credentialSingleton) Refresh(value bool) credentialOption {
+	return func(this *credentialConfig) { this.refresh = value }
+}
+
+// ResolverOptions are provided to ParseCredentialsFromJSON when credentials are read from JSON, e.g.
+// WithResolverScopes or WithResolverSelfSigned.
+func (credentialSingleton) ResolverOptions(values ...ResolverOption) credentialOption {
+	return func(this *credentialConfig) { this.resolverOptions = append(this.resolverOptions, values...) }
+}
+
+// MetadataTimeout bounds the attempt to reach the GCE metadata server (see GCE_METADATA_HOST), which is the final
+// source of credentials consulted; zero disables the attempt altogether. Where no metadata server answers (i.e. off
+// GCP), only the first Read waits for the timeout. Failures reported by the metadata server are skipped as well,
+// leaving the reason in the CredentialsChainError.
+func (credentialSingleton) MetadataTimeout(value time.Duration) credentialOption {
+	return func(this *credentialConfig) { this.metadataTimeout = value }
+}
+
+// Providers replaces the chain of providers consulted, in order, by Read; the default is CredentialProviders.Default().
+func (credentialSingleton) Providers(values ...CredentialProvider) credentialOption {
+	return func(this *credentialConfig) { this.providers = values }
+}
 func (credentialSingleton) apply(options ...credentialOption) credentialOption {
 	return func(this *credentialConfig) {
 		for _, option := range CredentialOptions.defaults(options...) {
 			option(this)
 		}
+		if len(this.vaultLogin.mount) == 0 {
+			this.vaultLogin.mount = this.vaultLogin.method
+		}
 	}
 }
 func (credentialSingleton) defaults(options ...credentialOption) []credentialOption {
@@ -198,6 +354,9 @@ func (credentialSingleton) defaults(options ...credentialOption) []credentialOpt
 		CredentialOptions.EnvironmentReader(&externalSystem{}),
 		CredentialOptions.VaultServer(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")),
 		CredentialOptions.VaultKey(os.Getenv("VAULT_KEY")),
+		CredentialOptions.VaultNamespace(os.Getenv("VAULT_NAMESPACE")),
+		CredentialOptions.MetadataTimeout(time.Millisecond * 500),
+		CredentialOptions.Providers(CredentialProviders.Default()...),
 	}, options...)
 }
 
@@ -0,0 +1,194 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestReadExplicitToken(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{})
+
+	credentials, err := reader.Read(context.Background(), "token.")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.BearerToken, should.Equal, "Bearer token")
+}
+func TestReadNothingFound(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": "127.0.0.1:1"})
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+func TestReadWellKnownFile_Home(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"HOME": "/home/user",
+		"/home/user/.config/gcloud/application_default_credentials.json": string(sampleServiceAccountJSON),
+	})
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+}
+func TestReadWellKnownFile_CloudSDKConfig(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"HOME":            "/home/user",
+		"CLOUDSDK_CONFIG": "/etc/gcloud",
+		"/etc/gcloud/application_default_credentials.json": string(sampleServiceAccountJSON),
+	})
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+}
+func TestReadWellKnownFile_Missing(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{"HOME": "/home/user", "GCE_METADATA_HOST": "127.0.0.1:1"})
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+}
+func TestReadWellKnownFile_PrecededByEnvironment(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"HOME":                      "/home/user",
+		"GOOGLE_OAUTH_ACCESS_TOKEN": "token",
+		"/home/user/.config/gcloud/application_default_credentials.json": string(sampleServiceAccountJSON),
+	})
+
+	credentials, _ := reader.Read(context.Background(), "")
+
+	should.So(t, credentials.BearerToken, should.Equal, "Bearer token")
+}
+func TestReadWellKnownFile_ExternalAccountSubjectTokenFromFileReader(t *testing.T) {
+	service := &FakeExternalAccountService{}
+	reader := newTestCredentialsReader(FakeEnvironment{
+		"HOME": "/home/user",
+		"/home/user/.config/gcloud/application_default_credentials.json": string(externalAccountJSON(`"file": "/var/run/token"`, "")),
+		"/var/run/token": "subject-token",
+	}, CredentialOptions.HTTPClient(service))
+
+	credentials, err := reader.Read(context.Background(), "")
+	should.So(t, err, should.BeNil)
+	_, err = credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.exchange.Get("subject_token"), should.Equal, "subject-token")
+}
+func TestReadMetadataServer(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusOK, metadataFlavor)
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": server.Listener.Addr().String()})
+
+	credentials, err := reader.Read(context.Background(), "")
+	should.So(t, err, should.BeNil)
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+
+	should.So(t, request.Header.Get("Authorization"), should.Equal, "Bearer metadata-token")
+	should.So(t, server.requests, should.Equal, 1) // the token fetched by the probe is cached
+}
+func TestReadMetadataServer_Impostor(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusOK, "")
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": server.Listener.Addr().String()})
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+}
+func TestReadMetadataServer_UnavailableNotProbedAgain(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusOK, "")
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": server.Listener.Addr().String()})
+
+	_, err1 := reader.Read(context.Background(), "")
+	_, err2 := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err1, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, errors.Is(err2, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, server.requests, should.Equal, 1)
+}
+func TestReadMetadataServer_Failure(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusNotFound, metadataFlavor)
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": server.Listener.Addr().String()})
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue) // skipped, as other providers are
+	should.So(t, strings.Contains(err.Error(), "[404]"), should.BeTrue)
+}
+func TestReadMetadataServer_Disabled(t *testing.T) {
+	server := newFakeMetadataServer(http.StatusOK, metadataFlavor)
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{"GCE_METADATA_HOST": server.Listener.Addr().String()},
+		CredentialOptions.MetadataTimeout(0))
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, server.requests, should.Equal, 0)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+func newTestCredentialsReader(environment FakeEnvironment, options ...credentialOption) CredentialsReader {
+	return NewCredentialsReader(append([]credentialOption{
+		CredentialOptions.EnvironmentReader(environment),
+		CredentialOptions.FileReader(environment),
+		CredentialOptions.VaultServer("", ""),
+		CredentialOptions.VaultKey(""),
+		CredentialOptions.MetadataTimeout(time.Millisecond * 100),
+	}, options...)...)
+}
+
+// FakeEnvironment holds environment variables as well as file contents (keyed by path).
+type FakeEnvironment map[string]string
+
+func (this FakeEnvironment) LookupEnv(key string) (string, bool) {
+	value, found := this[key]
+	return value, found
+}
+func (this FakeEnvironment) ReadFile(path string) ([]byte, error) {
+	if value, found := this[path]; found {
+		return []byte(value), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+type FakeMetadataServer struct {
+	*httptest.Server
+	requests int
+}
+
+func newFakeMetadataServer(statusCode int, flavor string) *FakeMetadataServer {
+	this := &FakeMetadataServer{}
+	this.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == metadataEmailPath {
+			response.Header().Set("Metadata-Flavor", "Google")
+			_, _ = response.Write([]byte("attached@project.iam.gserviceaccount.com"))
+			return
+		}
+		this.requests++
+		if request.URL.Path != metadataTokenPath || request.Header.Get("Metadata-Flavor") != "Google" {
+			statusCode = http.StatusBadRequest
+		}
+		if len(flavor) > 0 {
+			response.Header().Set("Metadata-Flavor", flavor)
+		}
+		response.Header().Set("Content-Type", "application/json")
+		response.WriteHeader(statusCode)
+		_, _ = response.Write([]byte(`{"access_token":"metadata-token","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	return this
+}
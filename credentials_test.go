@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/smarty/gcs/internal/should"
 )
@@ -36,7 +37,9 @@ func TestClientIdentity(t *testing.T) {
 
 	parsed, err := ParseCredentialsFromJSON(sampleClientIdentityJSON, WithResolverClient(client))
 
-	should.So(t, parsed, should.Equal, Credentials{BearerToken: "ResolvedTokenType ResolvedAccessToken"})
+	should.So(t, parsed.BearerToken, should.Equal, "ResolvedTokenType ResolvedAccessToken")
+	should.So(t, parsed.Provenance.Type, should.Equal, "authorized_user")
+	should.So(t, parsed.Provenance.Expiration.After(time.Now().Add(time.Minute*59)), should.BeTrue)
 	should.So(t, err, should.BeNil)
 }
 
@@ -0,0 +1,302 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// vaultLogin describes how a Vault token is obtained when one isn't provided (see VAULT_TOKEN).
+// https://developer.hashicorp.com/vault/docs/auth/approle
+// https://developer.hashicorp.com/vault/docs/auth/kubernetes
+type vaultLogin struct {
+	method   string // vaultAppRole or vaultKubernetes
+	mount    string // defaults to the name of the method
+	roleID   string
+	secretID string
+	role     string
+	jwtPath  string
+}
+
+// vaultSession holds the Vault token in use across calls to Read, renewing its lease (or logging in again) before it
+// expires.
+type vaultSession struct {
+	mutex     sync.Mutex
+	token     string
+	renewable bool
+	renewAt   time.Time // zero: no renewal required
+	now       func() time.Time
+}
+
+func (this *defaultReader) resolveGoogleAccessToken(ctx context.Context) (Credentials, error) {
+	token, err := this.vaultAuthenticate(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	response, err := this.vaultRequest(ctx, "GET", this.vaultKey, token, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusBadRequest {
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return Credentials{}, fmt.Errorf("the Vault token provided did not have permission to read from [%s]", this.vaultKey)
+	} else if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return Credentials{}, fmt.Errorf("unexpected status from the Vault server [%d]", response.StatusCode)
+	}
+
+	if strings.ToLower(response.Header.Get("Content-Type")) != "application/json" {
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return Credentials{}, fmt.Errorf("unknown Content-Type from the Vault server [%s]", response.Header.Get("Content-Type"))
+	}
+
+	raw, err := io.ReadAll(response.Body)
+	if errors.Is(err, context.Canceled) {
+		return Credentials{}, context.Canceled
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return Credentials{}, context.DeadlineExceeded
+	} else if err != nil {
+		return Credentials{}, fmt.Errorf("unable to read response from the Vault server: %w", err)
+	} else if len(raw) == 0 {
+		return Credentials{}, fmt.Errorf("zero-length response returned from the Vault server")
+	}
+
+	body := struct {
+		LeaseID string `json:"lease_id"`
+		Data    struct {
+			Token          string `json:"token"`
+			ExpiresAt      int64  `json:"expires_at_seconds"`
+			PrivateKeyData string `json:"private_key_data"`
+		} `json:"data"`
+	}{}
+
+	err = json.Unmarshal(raw, &body)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to parse response body returned from the Vault server: %w", err)
+	} else if len(body.Data.PrivateKeyData) > 0 {
+		return this.resolveGoogleServiceAccountKey(ctx, body.LeaseID, body.Data.PrivateKeyData)
+	}
+
+	accessToken := strings.Trim(body.Data.Token, ". ") // eliminate spaces and periods from the end (and beginning)
+	if len(accessToken) == 0 {
+		return Credentials{}, fmt.Errorf("no access token was returned from the Vault server: %w", err)
+	}
+
+	provenance := Provenance{Location: this.vaultLocation()}
+	if body.Data.ExpiresAt > 0 {
+		provenance.Expiration = time.Unix(body.Data.ExpiresAt, 0)
+	}
+
+	return Credentials{BearerToken: "Bearer " + accessToken, Provenance: provenance}, nil
+}
+
+// resolveGoogleServiceAccountKey parses the service account key generated by Vault's Google Cloud Secrets Engine (e.g.
+// "gcp/roleset/:name/key" or "gcp/static-account/:name/key"); the lease of the key is recorded by the Provenance such
+// that it may be revoked (see LeasedCredentialsReader).
+// https://developer.hashicorp.com/vault/api-docs/secret/gcp#generate-secret-iam-service-account-creds-oauth2-access-token
+func (this *defaultReader) resolveGoogleServiceAccountKey(ctx context.Context, leaseID, value string) (Credentials, error) {
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to base64 decode private key data returned from the Vault server: %w", err)
+	}
+
+	credentials, err := this.parseJSON(ctx, raw, this.vaultLocation())
+	if err != nil {
+		_ = this.vaultRevoke(ctx, leaseID) // the key is of no use to anyone
+		return Credentials{}, err
+	}
+
+	credentials.Provenance.Lease = leaseID
+	return credentials, nil
+}
+
+// vaultLease revokes the lease of a generated service account key (at most once) when closed, waiting no longer than
+// vaultRevokeTimeout for the Vault server; credentials without a lease hold nothing to release.
+type vaultLease struct {
+	reader  *defaultReader
+	leaseID string
+	once    sync.Once
+	err     error
+}
+
+func (this *vaultLease) Close() error {
+	this.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), vaultRevokeTimeout)
+		defer cancel()
+		this.err = this.reader.vaultRevoke(ctx, this.leaseID)
+	})
+	return this.err
+}
+
+func (this *defaultReader) vaultLocation() string {
+	return strings.TrimRight(this.vaultAddress, "/") + "/v1/" + this.vaultKey
+}
+
+// https://developer.hashicorp.com/vault/api-docs/system/leases#revoke-lease
+func (this *defaultReader) vaultRevoke(ctx context.Context, leaseID string) error {
+	if len(leaseID) == 0 {
+		return nil
+	}
+
+	token, err := this.vaultAuthenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	response, err := this.vaultRequest(ctx, "PUT", "sys/leases/revoke", token, map[string]string{"lease_id": leaseID})
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+	_, _ = io.Copy(io.Discard, response.Body) // drain response body
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to revoke Vault lease [%s] (status %d)", leaseID, response.StatusCode)
+	}
+
+	return nil
+}
+
+// vaultAuthenticate returns a Vault token which remains valid: the current token, the current token after renewing its
+// lease, or a new token obtained by logging in (when configured) or from VAULT_TOKEN.
+func (this *defaultReader) vaultAuthenticate(ctx context.Context) (string, error) {
+	session := this.vaultSession
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if len(session.token) > 0 && (session.renewAt.IsZero() || session.now().Before(session.renewAt)) {
+		return session.token, nil
+	}
+
+	if len(session.token) > 0 && session.renewable {
+		err := this.vaultStore(ctx, "auth/token/renew-self", session.token, struct{}{})
+		if err == nil || len(this.vaultLogin.method) == 0 {
+			return session.token, err
+		} // otherwise, log in again
+	}
+
+	if len(this.vaultLogin.method) > 0 {
+		body, err := this.vaultLoginBody()
+		if err != nil {
+			return "", err
+		}
+		if err = this.vaultStore(ctx, "auth/"+this.vaultLogin.mount+"/login", "", body); err != nil {
+			return "", err
+		}
+		return session.token, nil
+	}
+
+	if this.vaultRenew {
+		if err := this.vaultStore(ctx, "auth/token/renew-self", this.vaultToken, struct{}{}); err != nil {
+			return "", err
+		}
+		return session.token, nil
+	}
+
+	session.token = this.vaultToken
+	return session.token, nil
+}
+func (this *defaultReader) vaultLoginBody() (any, error) {
+	if this.vaultLogin.method == vaultAppRole {
+		return map[string]string{"role_id": this.vaultLogin.roleID, "secret_id": this.vaultLogin.secretID}, nil
+	}
+
+	// projected service account tokens are rotated by the kubelet, so the token is read again for each login
+	raw, err := this.fileReader.ReadFile(this.vaultLogin.jwtPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Kubernetes service account token [%s]: %w", this.vaultLogin.jwtPath, err)
+	}
+
+	return map[string]string{"role": this.vaultLogin.role, "jwt": strings.TrimSpace(string(raw))}, nil
+}
+
+// vaultStore performs a login or token renewal and retains the resulting token and its lease.
+// https://developer.hashicorp.com/vault/api-docs/auth/token#renew-a-token-self
+func (this *defaultReader) vaultStore(ctx context.Context, path, token string, body any) error {
+	response, err := this.vaultRequest(ctx, "POST", path, token, body)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, response.Body) // drain response body
+		return fmt.Errorf("unable to authenticate with the Vault server [%s] (status %d)", path, response.StatusCode)
+	}
+
+	parsed := struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+			Renewable     bool   `json:"renewable"`
+		} `json:"auth"`
+	}{}
+	if err = json.NewDecoder(response.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("unable to parse response body returned from the Vault server: %w", err)
+	} else if len(parsed.Auth.ClientToken) == 0 {
+		return fmt.Errorf("no client token was returned from the Vault server [%s]", path)
+	}
+
+	session := this.vaultSession
+	session.token = parsed.Auth.ClientToken
+	session.renewable = parsed.Auth.Renewable
+	session.renewAt = time.Time{}
+	if lease := time.Duration(parsed.Auth.LeaseDuration) * time.Second; lease > 0 {
+		session.renewAt = session.now().Add(lease * 2 / 3)
+	}
+
+	return nil
+}
+func (this *defaultReader) vaultRequest(ctx context.Context, method, path, token string, body any) (*http.Response, error) {
+	parsed, err := url.Parse(this.vaultAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse value specified in VAULT_ADDR: %w", err)
+	} else if len(parsed.Scheme) == 0 || len(parsed.Host) == 0 {
+		return nil, fmt.Errorf("unable to parse value specified in VAULT_ADDR: the format must be https://domain:port")
+	}
+
+	var content io.Reader
+	if body != nil {
+		raw, _ := json.Marshal(body)
+		content = bytes.NewReader(raw)
+	}
+
+	request, _ := http.NewRequest(method, parsed.Scheme+"://"+parsed.Host+"/v1/"+path, content)
+	if len(token) > 0 {
+		request.Header["X-Vault-Token"] = []string{token}
+	}
+	if len(this.vaultNamespace) > 0 {
+		request.Header["X-Vault-Namespace"] = []string{this.vaultNamespace}
+	}
+
+	response, err := this.client.Do(request.WithContext(ctx))
+	if errors.Is(err, context.Canceled) {
+		return nil, context.Canceled
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return nil, context.DeadlineExceeded
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to connect to the configured Vault server: %w", err)
+	}
+
+	return response, nil
+}
+
+const (
+	vaultAppRole             = "approle"
+	vaultKubernetes          = "kubernetes"
+	defaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+var vaultRevokeTimeout = time.Second * 10
@@ -0,0 +1,290 @@
+package gcs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestReadVault_StaticToken(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("/gcp/roleset/name/token"),
+		CredentialOptions.VaultNamespace("team/"))
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.BearerToken, should.Equal, "Bearer vault-access-token")
+	should.So(t, server.paths(), should.Equal, []string{"GET /v1/gcp/roleset/name/token"})
+	should.So(t, server.requests[0].Header.Get("X-Vault-Token"), should.Equal, "static-token")
+	should.So(t, server.requests[0].Header.Get("X-Vault-Namespace"), should.Equal, "team")
+}
+func TestReadVault_PermissionDenied(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	server.statuses["/v1/gcp/roleset/name/token"] = http.StatusForbidden
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/token"))
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, err.Error(), should.Equal, "the Vault token provided did not have permission to read from [gcp/roleset/name/token]")
+}
+func TestReadVault_AppRoleLogin(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, ""), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultAppRole("role-id", "secret-id"))
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.BearerToken, should.Equal, "Bearer vault-access-token")
+	should.So(t, server.paths(), should.Equal, []string{"POST /v1/auth/approle/login", "GET /v1/gcp/roleset/name/token"})
+	should.So(t, server.bodies[0], should.Equal, map[string]string{"role_id": "role-id", "secret_id": "secret-id"})
+	should.So(t, server.requests[0].Header.Get("X-Vault-Token"), should.Equal, "")
+	should.So(t, server.requests[1].Header.Get("X-Vault-Token"), should.Equal, "client-token-1")
+}
+func TestReadVault_KubernetesLogin(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{defaultKubernetesJWTPath: "service-account-jwt\n"},
+		CredentialOptions.VaultServer(server.URL, "ignored-token"), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultAuthMount("/k8s-cluster/"), CredentialOptions.VaultKubernetes("app", ""))
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, server.paths(), should.Equal, []string{"POST /v1/auth/k8s-cluster/login", "GET /v1/gcp/roleset/name/token"})
+	should.So(t, server.bodies[0], should.Equal, map[string]string{"role": "app", "jwt": "service-account-jwt"})
+}
+func TestReadVault_KubernetesTokenMissing(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, ""), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultKubernetes("app", "/path/to/token"))
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, strings.HasPrefix(err.Error(), "unable to read Kubernetes service account token [/path/to/token]"), should.BeTrue)
+	should.So(t, len(server.requests), should.Equal, 0)
+}
+func TestReadVault_TokenReusedThenRenewed(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, ""), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultAppRole("role-id", "secret-id"))
+	now := time.Now()
+	reader.(*defaultReader).vaultSession.now = func() time.Time { return now }
+
+	_, _ = reader.Read(context.Background(), "")
+	_, _ = reader.Read(context.Background(), "")
+	now = now.Add(time.Minute * 41) // two thirds of the hour-long lease
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, server.paths(), should.Equal, []string{
+		"POST /v1/auth/approle/login",
+		"GET /v1/gcp/roleset/name/token",
+		"GET /v1/gcp/roleset/name/token",
+		"POST /v1/auth/token/renew-self",
+		"GET /v1/gcp/roleset/name/token",
+	})
+	should.So(t, server.requests[3].Header.Get("X-Vault-Token"), should.Equal, "client-token-1")
+	should.So(t, server.requests[4].Header.Get("X-Vault-Token"), should.Equal, "client-token-2")
+}
+func TestReadVault_RenewalRejectedLogsInAgain(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	server.statuses["/v1/auth/token/renew-self"] = http.StatusForbidden
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, ""), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultAppRole("role-id", "secret-id"))
+	now := time.Now()
+	reader.(*defaultReader).vaultSession.now = func() time.Time { return now }
+
+	_, _ = reader.Read(context.Background(), "")
+	now = now.Add(time.Hour)
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, server.paths(), should.Equal, []string{
+		"POST /v1/auth/approle/login",
+		"GET /v1/gcp/roleset/name/token",
+		"POST /v1/auth/token/renew-self",
+		"POST /v1/auth/approle/login",
+		"GET /v1/gcp/roleset/name/token",
+	})
+}
+func TestReadVault_StaticTokenRenewed(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/token"),
+		CredentialOptions.VaultRenew(true))
+
+	_, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, server.paths(), should.Equal, []string{"POST /v1/auth/token/renew-self", "GET /v1/gcp/roleset/name/token"})
+	should.So(t, server.requests[0].Header.Get("X-Vault-Token"), should.Equal, "static-token")
+}
+func TestReadVault_ServiceAccountKey(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	server.secrets["/v1/gcp/roleset/name/key"] = `{"lease_id": "gcp/roleset/name/key/lease-id", "lease_duration": 2592000,
+		"data": {"key_algorithm": "KEY_ALG_RSA_2048", "key_type": "TYPE_GOOGLE_CREDENTIALS_FILE",
+		"private_key_data": "` + base64.StdEncoding.EncodeToString(sampleServiceAccountJSON) + `"}}`
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/key"))
+
+	credentials, lease, err := reader.(LeasedCredentialsReader).ReadLeased(context.Background(), "")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	should.So(t, credentials.BearerToken, should.Equal, "")
+	should.So(t, credentials.Provenance.Lease, should.Equal, "gcp/roleset/name/key/lease-id")
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+	should.So(t, len(request.URL.Query().Get("Signature")) > 0, should.BeTrue)
+
+	should.So(t, lease.Close(), should.BeNil)
+	should.So(t, lease.Close(), should.BeNil)
+	should.So(t, server.paths(), should.Equal, []string{"GET /v1/gcp/roleset/name/key", "PUT /v1/sys/leases/revoke"})
+	should.So(t, server.bodies[1], should.Equal, map[string]string{"lease_id": "gcp/roleset/name/key/lease-id"})
+	should.So(t, server.requests[1].Header.Get("X-Vault-Token"), should.Equal, "static-token")
+}
+func TestReadVault_ServiceAccountKeyMalformed(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	server.secrets["/v1/gcp/roleset/name/key"] = `{"lease_id": "lease-id", "data": {"private_key_data": "` +
+		base64.StdEncoding.EncodeToString([]byte("{not json")) + `"}}`
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/key"))
+
+	credentials, err := reader.Read(context.Background(), "")
+
+	should.So(t, err, should.Equal, ErrMalformedJSON)
+	should.So(t, credentials, should.Equal, Credentials{})
+	should.So(t, server.paths(), should.Equal, []string{"GET /v1/gcp/roleset/name/key", "PUT /v1/sys/leases/revoke"})
+}
+func TestReadVault_ServiceAccountKeyLeaseNotRevokedByRead(t *testing.T) {
+	server := newFakeVaultServer()
+	defer server.Close()
+	server.secrets["/v1/gcp/roleset/name/key"] = `{"lease_id": "lease-id", "data": {
+		"private_key_data": "` + base64.StdEncoding.EncodeToString(sampleServiceAccountJSON) + `"}}`
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/key"))
+
+	credentials, err := reader.Read(context.Background(), "")
+	copied := credentials
+
+	should.So(t, err, should.BeNil)
+	should.So(t, copied.Provenance.Lease, should.Equal, "lease-id")
+	should.So(t, server.paths(), should.Equal, []string{"GET /v1/gcp/roleset/name/key"})
+}
+func TestReadLeased_RevokeTimeout(t *testing.T) {
+	defer func(original time.Duration) { vaultRevokeTimeout = original }(vaultRevokeTimeout)
+	vaultRevokeTimeout = time.Millisecond * 50
+	unresponsive := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/v1/sys/leases/revoke" {
+			<-unresponsive
+			return
+		}
+		response.Header().Set("Content-Type", "application/json")
+		_, _ = response.Write([]byte(`{"lease_id": "lease-id", "data": {
+			"private_key_data": "` + base64.StdEncoding.EncodeToString(sampleServiceAccountJSON) + `"}}`))
+	}))
+	defer server.Close()
+	defer close(unresponsive)
+	reader := newTestCredentialsReader(FakeEnvironment{},
+		CredentialOptions.VaultServer(server.URL, "static-token"), CredentialOptions.VaultKey("gcp/roleset/name/key"))
+	_, lease, err := reader.(LeasedCredentialsReader).ReadLeased(context.Background(), "")
+	should.So(t, err, should.BeNil)
+
+	started := time.Now()
+	err = lease.Close()
+
+	should.So(t, errors.Is(err, context.DeadlineExceeded), should.BeTrue)
+	should.So(t, time.Since(started) < time.Second, should.BeTrue)
+}
+func TestReadLeased_NothingHeld(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{})
+
+	_, lease, err := reader.(LeasedCredentialsReader).ReadLeased(context.Background(), "Bearer token")
+
+	should.So(t, err, should.BeNil)
+	should.So(t, lease.Close(), should.BeNil)
+}
+func TestReadLeased_Failure(t *testing.T) {
+	reader := newTestCredentialsReader(FakeEnvironment{}, CredentialOptions.Providers(CredentialProviders.ExplicitToken()))
+
+	_, lease, err := reader.(LeasedCredentialsReader).ReadLeased(context.Background(), "")
+
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeTrue)
+	should.So(t, lease, should.BeNil)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+// FakeVaultServer issues numbered client tokens for logins and renewals, revokes leases, and returns an access token
+// for any other path (unless another secret has been provided for it).
+type FakeVaultServer struct {
+	*httptest.Server
+	statuses map[string]int
+	secrets  map[string]string
+	requests []*http.Request
+	bodies   []map[string]string
+	issued   int
+}
+
+func newFakeVaultServer() *FakeVaultServer {
+	this := &FakeVaultServer{statuses: map[string]int{}, secrets: map[string]string{}}
+	this.Server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(request.Body).Decode(&body)
+		this.requests = append(this.requests, request)
+		this.bodies = append(this.bodies, body)
+
+		response.Header().Set("Content-Type", "application/json")
+		if status, found := this.statuses[request.URL.Path]; found {
+			response.WriteHeader(status)
+			return
+		}
+
+		if secret, found := this.secrets[request.URL.Path]; found {
+			_, _ = response.Write([]byte(secret))
+		} else if request.Method == "PUT" {
+			response.WriteHeader(http.StatusNoContent)
+		} else if request.Method == "POST" {
+			this.issued++
+			raw, _ := json.Marshal(map[string]any{"auth": map[string]any{
+				"client_token": "client-token-" + strconv.Itoa(this.issued), "lease_duration": 3600, "renewable": true,
+			}})
+			_, _ = response.Write(raw)
+		} else {
+			_, _ = response.Write([]byte(`{"data": {"token": "vault-access-token...", "expires_at_seconds": 1700000000}}`))
+		}
+	}))
+	return this
+}
+func (this *FakeVaultServer) paths() (paths []string) {
+	for _, request := range this.requests {
+		paths = append(paths, request.Method+" "+request.URL.Path)
+	}
+	return paths
+}
@@ -0,0 +1,222 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RangedDownload fetches an object as a number of byte ranges requested concurrently, writing each into place as it
+// arrives. Every range is retried on its own, continuing from the last byte received. All ranges are pinned to the
+// generation of the object when the download began such that an object replaced in the meantime fails the download
+// (ErrObjectNotFound) rather than yielding a mixture of both versions.
+//
+// Ranges address the object as stored, so objects stored with Content-Encoding: gzip (which the service would
+// otherwise decompress on the fly, ignoring the range) should be retrieved with a single GET instead.
+type RangedDownload struct {
+	client      *Client
+	context     context.Context
+	rangeSize   int64
+	concurrency int
+	retry       retryConfig
+}
+
+// NewRangedDownload accepts the same options as a GET request (e.g. WithCredentials, WithBucket, WithResource,
+// GetWithGeneration) as well as DownloadWithRangeSize, DownloadWithConcurrency, and DownloadWithRetry.
+func NewRangedDownload(client httpClient, options ...Option) (*RangedDownload, error) {
+	input := newModel(GET, options)
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	if input.downloadRangeSize <= 0 {
+		input.downloadRangeSize = defaultRangeSize
+	}
+	if input.downloadConcurrency <= 0 {
+		input.downloadConcurrency = defaultConcurrency
+	}
+
+	var retry retryConfig
+	RetryOptions.apply(input.downloadRetry...)(&retry)
+
+	return &RangedDownload{
+		client:      NewClient(client, options...),
+		context:     input.context,
+		rangeSize:   input.downloadRangeSize,
+		concurrency: input.downloadConcurrency,
+		retry:       retry,
+	}, nil
+}
+
+// Download writes the object to the writer provided, which must accept writes at distinct offsets from multiple
+// goroutines at once (e.g. *os.File). The attributes of the object are returned (without a Body); should any range
+// fail, the first error is returned and the remaining ranges are abandoned.
+func (this *RangedDownload) Download(writer io.WriterAt) (Object, error) {
+	head, err := this.client.Head()
+	if err != nil {
+		return Object{}, err
+	} else if head.Size < 0 {
+		return Object{}, ErrUnknownObjectSize // neither Content-Length nor x-goog-stored-content-length was reported
+	}
+
+	ctx, cancel := context.WithCancel(this.context)
+	defer cancel()
+
+	var failure error
+	var once sync.Once
+	var waiter sync.WaitGroup
+
+	spans := make(chan ContentRange)
+	go func() {
+		defer close(spans)
+		for first := int64(0); first < head.Size; first += this.rangeSize {
+			select {
+			case spans <- ContentRange{First: first, Last: min(first+this.rangeSize, head.Size) - 1, Size: head.Size}:
+			case <-ctx.Done():
+				once.Do(func() { failure = ctx.Err() }) // unless a range failed first
+				return
+			}
+		}
+	}()
+
+	workers := min(int64(this.concurrency), (head.Size+this.rangeSize-1)/this.rangeSize)
+	for i := int64(0); i < workers; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			for span := range spans {
+				if err := this.fetch(ctx, writer, head.Generation, span); err != nil {
+					once.Do(func() { failure = err; cancel() })
+				}
+			}
+		}()
+	}
+	waiter.Wait()
+
+	if failure != nil {
+		return Object{}, failure
+	}
+	return head, nil
+}
+
+// fetch retrieves a single range, continuing from the last byte written should an attempt fail part way through.
+func (this *RangedDownload) fetch(ctx context.Context, writer io.WriterAt, generation int64, span ContentRange) error {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err // abandoned, e.g. another range failed
+		}
+
+		written, err := this.fetchOnce(ctx, writer, generation, span)
+		if err == nil {
+			return nil
+		}
+
+		span.First += written
+		var transient *transientError
+		if attempt >= this.retry.maxAttempts || !errors.As(err, &transient) {
+			return err
+		}
+
+		delay := fullJitter(attempt, this.retry.initialBackoff, this.retry.maxBackoff)
+		if transient.hinted && transient.retryAfter > this.retry.maxBackoff {
+			return err // the service asked for a longer pause than the maximum backoff
+		} else if transient.hinted {
+			delay = transient.retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err // no time remains for another attempt
+		}
+
+		if err = sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// fetchOnce makes a single attempt at the range, marking those failures which another attempt may overcome as a
+// *transientError; everything else (e.g. invalid credentials, a range which was not honored, or a failure to write)
+// is returned as is.
+func (this *RangedDownload) fetchOnce(ctx context.Context, writer io.WriterAt, generation int64, span ContentRange) (int64, error) {
+	request, err := NewRequest(GET, this.client.options([]Option{
+		WithContext(ctx),
+		GetWithRange(span.First, span.Length()),
+		WithConditionalOption(GetWithGeneration(strconv.FormatInt(generation, 10)), generation > 0),
+	})...)
+	if err != nil {
+		return 0, err
+	}
+
+	response, err := this.client.client.Do(request)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, err
+	} else if err != nil {
+		return 0, &transientError{error: err}
+	}
+
+	defer drain(response)
+	if err = ParseErrorResponse(response); err != nil && isTransientStatus(response.StatusCode) {
+		retryAfter, hinted := parseRetryAfter(response.Header.Get("Retry-After"))
+		return 0, &transientError{error: err, retryAfter: retryAfter, hinted: hinted}
+	} else if err != nil {
+		return 0, err
+	}
+
+	object, err := newObject(response)
+	if err != nil {
+		return 0, err
+	} else if object.Range == nil || object.Range.First != span.First || object.Range.Last != span.Last {
+		return 0, fmt.Errorf("%w: requested bytes %d-%d", ErrUnexpectedContentRange, span.First, span.Last)
+	}
+
+	target := rangeWriter{inner: io.NewOffsetWriter(writer, span.First)}
+	written, err := io.Copy(target, io.LimitReader(response.Body, span.Length()))
+	if err == nil && written < span.Length() {
+		err = io.ErrUnexpectedEOF
+	}
+
+	var writeErr rangeWriteError
+	if err == nil || errors.As(err, &writeErr) {
+		return written, err
+	}
+	return written, &transientError{error: err} // the response body was interrupted
+}
+
+// transientError marks a failure which another attempt may overcome: the transport failed, the response body was
+// interrupted, or the service responded with a transient status (see isTransientStatus), possibly indicating when to
+// try again (Retry-After).
+type transientError struct {
+	error
+	retryAfter time.Duration
+	hinted     bool
+}
+
+func (this *transientError) Unwrap() error { return this.error }
+
+// rangeWriter distinguishes failures to write a range from failures to read it, the latter of which are retried.
+type rangeWriter struct{ inner io.Writer }
+
+func (this rangeWriter) Write(raw []byte) (int, error) {
+	written, err := this.inner.Write(raw)
+	if err != nil {
+		err = rangeWriteError{err}
+	}
+	return written, err
+}
+
+type rangeWriteError struct{ error }
+
+func (this rangeWriteError) Unwrap() error { return this.error }
+
+const (
+	defaultRangeSize   = 16 * 1024 * 1024
+	defaultConcurrency = 8
+)
+
+var (
+	ErrUnexpectedContentRange = errors.New("response does not contain the requested range")
+	ErrUnknownObjectSize      = errors.New("the size of the object was not reported")
+)
@@ -0,0 +1,342 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestRangedDownload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10) + "abc")
+	service := &FakeDownloadService{content: content}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRangeSize(25), DownloadWithConcurrency(3))
+	writer := &FakeWriterAt{}
+
+	object, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, object.Size, should.Equal, int64(103))
+	should.So(t, object.Generation, should.Equal, int64(42))
+	should.So(t, object.Body, should.BeNil)
+	should.So(t, service.requests[0].Method, should.Equal, "HEAD")
+	ranges := service.ranges()
+	sort.Strings(ranges) // requested concurrently
+	should.So(t, ranges, should.Equal, []string{
+		"bytes=0-24", "bytes=100-102", "bytes=25-49", "bytes=50-74", "bytes=75-99",
+	})
+	for _, request := range service.requests[1:] {
+		should.So(t, request.URL.Query().Get("generation"), should.Equal, "42")
+	}
+}
+func TestRangedDownload_ClientDefaults(t *testing.T) {
+	content := []byte("hello, world")
+	service := &FakeDownloadService{content: content}
+	client := NewClient(service, WithBucket("bucket"))
+	download, _ := client.RangedDownload(WithResource("file.txt"))
+	writer := &FakeWriterAt{}
+
+	_, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-11"})
+	should.So(t, service.requests[1].URL.Path, should.Equal, "/bucket/file.txt")
+}
+func TestRangedDownload_EmptyObject(t *testing.T) {
+	service := &FakeDownloadService{}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"))
+
+	object, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, err, should.BeNil)
+	should.So(t, object.Size, should.Equal, int64(0))
+	should.So(t, len(service.requests), should.Equal, 1)
+}
+func TestRangedDownload_InvalidRequest(t *testing.T) {
+	download, err := NewRangedDownload(&FakeDownloadService{}, WithResource("file.txt"))
+
+	should.So(t, download, should.BeNil)
+	should.So(t, err, should.Equal, ErrBucketMissing)
+}
+func TestRangedDownload_HeadFailure(t *testing.T) {
+	service := &FakeDownloadService{headStatus: http.StatusNotFound}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrObjectNotFound), should.BeTrue)
+	should.So(t, len(service.requests), should.Equal, 1)
+}
+func TestRangedDownload_UnknownSize(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), unknownSize: true}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"))
+
+	object, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, err, should.Equal, ErrUnknownObjectSize)
+	should.So(t, object, should.Equal, Object{})
+	should.So(t, len(service.requests), should.Equal, 1)
+}
+func TestRangedDownload_ResumesInterruptedRange(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 5))
+	service := &FakeDownloadService{content: content, interrupt: map[string]int{"bytes=20-39": 7}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRangeSize(20), DownloadWithConcurrency(1), DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+	writer := &FakeWriterAt{}
+
+	_, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-19", "bytes=20-39", "bytes=27-39", "bytes=40-49"})
+}
+func TestRangedDownload_RetriesTransientStatus(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 2))
+	service := &FakeDownloadService{content: content, statuses: map[string][]int{"bytes=10-19": {503, 429}}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRangeSize(10), DownloadWithConcurrency(1), DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+	writer := &FakeWriterAt{}
+
+	_, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9", "bytes=10-19", "bytes=10-19", "bytes=10-19"})
+}
+func TestRangedDownload_GivesUpAfterMaxAttempts(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), statuses: map[string][]int{"bytes=0-9": {503, 503, 503}}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.MaxAttempts(2), RetryOptions.Backoff(0, 0)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrUnexpectedStatus), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9", "bytes=0-9"})
+}
+func TestRangedDownload_ObjectReplaced(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), statuses: map[string][]int{"bytes=0-9": {404}}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrObjectNotFound), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_RangeNotHonored(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), ignoreRange: true}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRangeSize(5), DownloadWithConcurrency(1))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrUnexpectedContentRange), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-4"})
+}
+func TestRangedDownload_WriteFailureNotRetried(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789")}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+	writeErr := errors.New("disk full")
+
+	_, err := download.Download(&FakeWriterAt{err: writeErr})
+
+	should.So(t, errors.Is(err, writeErr), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_FailureAbandonsRemainingRanges(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 10))
+	service := &FakeDownloadService{content: content, statuses: map[string][]int{"bytes=0-9": {403}}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRangeSize(10), DownloadWithConcurrency(1))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrAccessDenied), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_ContextCanceled(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), statuses: map[string][]int{"bytes=0-9": {503}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"), WithContext(ctx),
+		DownloadWithRetry(RetryOptions.Backoff(time.Hour, time.Hour)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, context.Canceled), should.BeTrue)
+	should.So(t, len(service.ranges()) <= 1, should.BeTrue)
+}
+func TestRangedDownload_RetriesTransportFailure(t *testing.T) {
+	content := []byte("0123456789")
+	service := &FakeDownloadService{content: content, transport: map[string]int{"bytes=0-9": 2}}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+	writer := &FakeWriterAt{}
+
+	_, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9", "bytes=0-9", "bytes=0-9"})
+}
+func TestRangedDownload_HonorsRetryAfter(t *testing.T) {
+	content := []byte("0123456789")
+	service := &FakeDownloadService{content: content, statuses: map[string][]int{"bytes=0-9": {429}}, retryAfter: "0"}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(time.Hour, time.Hour)))
+	writer := &FakeWriterAt{}
+
+	_, err := download.Download(writer)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, writer.content, should.Equal, content)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9", "bytes=0-9"})
+}
+func TestRangedDownload_RetryAfterBeyondDeadline(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), statuses: map[string][]int{"bytes=0-9": {503}}, retryAfter: "3600"}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"), WithContext(ctx),
+		DownloadWithRetry(RetryOptions.Backoff(0, time.Hour*2)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrUnexpectedStatus), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_RetryAfterBeyondMaxBackoff(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), statuses: map[string][]int{"bytes=0-9": {503}}, retryAfter: "86400"}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(0, time.Second)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrUnexpectedStatus), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_MalformedContentRangeNotRetried(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789"), contentRange: "bytes 0-9"}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, ErrMalformedContentRange), should.BeTrue)
+	should.So(t, service.ranges(), should.Equal, []string{"bytes=0-9"})
+}
+func TestRangedDownload_CredentialFailureNotRetried(t *testing.T) {
+	service := &FakeDownloadService{content: []byte("0123456789")}
+	tokenErr := errors.New("token refresh failed")
+	source := &FakeTokenSource{tokens: []AccessToken{{Value: "token"}}, errAfter: 1, err: tokenErr}
+	download, _ := NewRangedDownload(service, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(Credentials{TokenSource: source}), DownloadWithRetry(RetryOptions.Backoff(0, 0)))
+
+	_, err := download.Download(&FakeWriterAt{})
+
+	should.So(t, errors.Is(err, tokenErr), should.BeTrue)
+	should.So(t, len(service.ranges()), should.Equal, 0)
+	should.So(t, source.calls.Load(), should.Equal, int32(2))
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+// FakeDownloadService serves HEAD and ranged GET requests for a single object (generation 42).
+type FakeDownloadService struct {
+	mutex        sync.Mutex
+	requests     []*http.Request
+	content      []byte
+	headStatus   int
+	statuses     map[string][]int // per Range header; consumed in order before the range is served
+	interrupt    map[string]int   // per Range header; the body fails after the given number of bytes (once)
+	ignoreRange  bool             // respond with the entire object (200) as when transcoding
+	unknownSize  bool             // omit the length of the object from the HEAD response
+	transport    map[string]int   // per Range header; the number of requests which fail in transport
+	retryAfter   string           // the Retry-After header of responses from statuses
+	contentRange string           // replaces the Content-Range header of ranged responses
+}
+
+func (this *FakeDownloadService) Do(request *http.Request) (*http.Response, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.requests = append(this.requests, request)
+	headers := http.Header{"X-Goog-Generation": {"42"}, "X-Goog-Stored-Content-Length": {strconv.Itoa(len(this.content))}}
+	if request.Method == "HEAD" && this.unknownSize {
+		response := newFakeResponse(http.StatusOK, "", http.Header{"X-Goog-Generation": {"42"}})
+		response.ContentLength = -1
+		return response, nil
+	} else if request.Method == "HEAD" {
+		return newFakeResponse(max(this.headStatus, http.StatusOK), "", headers), nil
+	} else if this.ignoreRange {
+		return newFakeResponse(http.StatusOK, string(this.content), headers), nil
+	}
+
+	value := request.Header.Get("Range")
+	if this.transport[value] > 0 {
+		this.transport[value]--
+		return nil, errFakeTransport
+	} else if statuses := this.statuses[value]; len(statuses) > 0 {
+		this.statuses[value] = statuses[1:]
+		return newFakeResponse(statuses[0], "", http.Header{"Retry-After": {this.retryAfter}}), nil
+	}
+
+	var first, last int
+	_, _ = fmt.Sscanf(value, "bytes=%d-%d", &first, &last)
+	headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(this.content)))
+	if len(this.contentRange) > 0 {
+		headers.Set("Content-Range", this.contentRange)
+	}
+	response := newFakeResponse(http.StatusPartialContent, string(this.content[first:last+1]), headers)
+
+	if length, found := this.interrupt[value]; found {
+		delete(this.interrupt, value)
+		partial := io.MultiReader(bytes.NewReader(this.content[first:first+length]), iotest.ErrReader(errFakeTransport))
+		response.Body = io.NopCloser(partial)
+	}
+	return response, nil
+}
+func (this *FakeDownloadService) ranges() (values []string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, request := range this.requests {
+		if request.Method == "GET" {
+			values = append(values, request.Header.Get("Range"))
+		}
+	}
+	return values
+}
+
+type FakeWriterAt struct {
+	mutex   sync.Mutex
+	content []byte
+	err     error
+}
+
+func (this *FakeWriterAt) WriteAt(raw []byte, offset int64) (int, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.err != nil {
+		return 0, this.err
+	}
+	if end := int(offset) + len(raw); end > len(this.content) {
+		this.content = append(this.content, make([]byte, end-len(this.content))...)
+	}
+	return copy(this.content[offset:], raw), nil
+}
@@ -0,0 +1,117 @@
+package gcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// APIError describes a failed request as reported by the storage service. It may be compared against the sentinel
+// errors below using errors.Is, e.g. errors.Is(err, ErrObjectNotFound).
+type APIError struct {
+	StatusCode int
+	Code       string // e.g. "NoSuchKey", "AccessDenied"
+	Message    string
+	UploadID   string // the X-GUploader-UploadID response header, useful when contacting Google Cloud support
+}
+
+// ParseErrorResponse returns nil for successful responses and an *APIError otherwise, in which case the error
+// document (if any) is read from the response body. Closing the body remains the responsibility of the caller.
+func ParseErrorResponse(response *http.Response) error {
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent, http.StatusPartialContent:
+		return nil
+	}
+
+	result := &APIError{StatusCode: response.StatusCode, UploadID: response.Header.Get(headerUploadID)}
+	if response.Body != nil {
+		raw, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBodyLength))
+		result.Code, result.Message = parseErrorBody(bytes.TrimSpace(raw))
+	}
+
+	return result
+}
+func parseErrorBody(raw []byte) (code, message string) {
+	if bytes.HasPrefix(raw, []byte("{")) {
+		var parsed struct {
+			Error       json.RawMessage `json:"error"`
+			Description string          `json:"error_description"` // OAuth token endpoints (RFC 6749)
+		}
+		if json.Unmarshal(raw, &parsed) != nil {
+			return "", string(raw)
+		} else if json.Unmarshal(parsed.Error, &code) == nil {
+			return code, parsed.Description
+		}
+
+		var detailed struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		}
+		_ = json.Unmarshal(parsed.Error, &detailed)
+		if code = detailed.Status; len(detailed.Errors) > 0 {
+			code = detailed.Errors[0].Reason
+		}
+		return code, detailed.Message
+	}
+
+	var parsed struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(raw, &parsed) != nil {
+		return "", strings.TrimSpace(string(raw)) // not an error document (e.g. an HTML page from a proxy)
+	}
+	return parsed.Code, parsed.Message
+}
+
+func (this *APIError) Error() string {
+	builder := &strings.Builder{}
+	appendTo(builder, "storage request failed [%d]", this.StatusCode)
+	appendIf(len(this.Code) > 0, builder, " %s", this.Code)
+	appendIf(len(this.Message) > 0, builder, ": %s", this.Message)
+	appendIf(len(this.UploadID) > 0, builder, " (upload ID: %s)", this.UploadID)
+	return builder.String()
+}
+func (this *APIError) Is(target error) bool {
+	return target == this.sentinel()
+}
+func (this *APIError) sentinel() error {
+	switch this.StatusCode {
+	case http.StatusNotModified:
+		return ErrNotModified
+	case http.StatusNotFound:
+		return ErrObjectNotFound
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAccessDenied
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusRequestedRangeNotSatisfiable:
+		return ErrRangeNotSatisfiable
+	default:
+		return ErrUnexpectedStatus
+	}
+}
+
+const (
+	headerUploadID     = "X-GUploader-UploadID"
+	maxErrorBodyLength = 64 * 1024
+)
+
+var (
+	ErrNotModified         = errors.New("object not modified")
+	ErrObjectNotFound      = errors.New("object not found")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+	ErrAccessDenied        = errors.New("access denied")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+	ErrUnexpectedStatus    = errors.New("unexpected HTTP status")
+)
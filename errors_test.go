@@ -0,0 +1,67 @@
+package gcs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestParseErrorResponse_Success(t *testing.T) {
+	err := ParseErrorResponse(newFakeResponse(http.StatusOK, "content", nil))
+
+	should.So(t, err, should.BeNil)
+}
+func TestParseErrorResponse_XML(t *testing.T) {
+	response := newFakeResponse(http.StatusNotFound, sampleErrorXML, http.Header{"X-Guploader-Uploadid": {"upload-id"}})
+
+	err := ParseErrorResponse(response)
+
+	should.So(t, err, should.Equal, &APIError{
+		StatusCode: http.StatusNotFound,
+		Code:       "NoSuchKey",
+		Message:    "The specified key does not exist.",
+		UploadID:   "upload-id",
+	})
+	should.So(t, err.Error(), should.Equal, "storage request failed [404] NoSuchKey: The specified key does not exist. (upload ID: upload-id)")
+}
+func TestParseErrorResponse_JSON(t *testing.T) {
+	err := ParseErrorResponse(newFakeResponse(http.StatusForbidden, sampleErrorJSON, nil))
+
+	should.So(t, err, should.Equal, &APIError{StatusCode: http.StatusForbidden, Code: "forbidden", Message: "Access denied."})
+}
+func TestParseErrorResponse_OAuth(t *testing.T) {
+	err := ParseErrorResponse(newFakeResponse(http.StatusBadRequest, `{"error": "invalid_grant", "error_description": "Token expired."}`, nil))
+
+	should.So(t, err, should.Equal, &APIError{StatusCode: http.StatusBadRequest, Code: "invalid_grant", Message: "Token expired."})
+}
+func TestParseErrorResponse_NotAnErrorDocument(t *testing.T) {
+	err := ParseErrorResponse(newFakeResponse(http.StatusBadGateway, " Bad Gateway\n", nil))
+
+	should.So(t, err, should.Equal, &APIError{StatusCode: http.StatusBadGateway, Message: "Bad Gateway"})
+}
+func TestAPIErrorSentinels(t *testing.T) {
+	assertSentinel(t, http.StatusNotModified, ErrNotModified)
+	assertSentinel(t, http.StatusNotFound, ErrObjectNotFound)
+	assertSentinel(t, http.StatusPreconditionFailed, ErrPreconditionFailed)
+	assertSentinel(t, http.StatusUnauthorized, ErrAccessDenied)
+	assertSentinel(t, http.StatusForbidden, ErrAccessDenied)
+	assertSentinel(t, http.StatusTooManyRequests, ErrRateLimited)
+	assertSentinel(t, http.StatusServiceUnavailable, ErrUnexpectedStatus)
+}
+func assertSentinel(t *testing.T, statusCode int, expected error) {
+	t.Helper()
+	err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: statusCode})
+
+	should.So(t, errors.Is(err, expected), should.BeTrue)
+	should.So(t, errors.Is(err, ErrCredentialsFailure), should.BeFalse)
+	var apiError *APIError
+	should.So(t, errors.As(err, &apiError), should.BeTrue)
+	should.So(t, apiError.StatusCode, should.Equal, statusCode)
+}
+
+const sampleErrorXML = `<?xml version='1.0' encoding='UTF-8'?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message><Details>No such object: bucket/file.txt</Details></Error>`
+
+const sampleErrorJSON = `{"error": {"code": 403, "message": "Access denied.", "errors": [{"message": "Access denied.", "domain": "global", "reason": "forbidden"}]}}`
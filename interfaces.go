@@ -1,11 +1,22 @@
 package gcs
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 type (
 	TokenResolver interface {
 		AccessToken(ClientIdentity) (AccessToken, error)
 	}
+	TokenSource interface {
+		Token(context.Context) (AccessToken, error)
+	}
+	// Signer produces the RSA-SHA256 (PKCS #1 v1.5) signature of the bytes provided, as required for signed URLs. The
+	// context is that of the request being signed (see WithContext).
+	Signer interface {
+		Sign(context.Context, []byte) ([]byte, error)
+	}
 	ClientIdentity struct {
 		ID           string `json:"client_id"`
 		Secret       string `json:"client_secret"`
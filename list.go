@@ -0,0 +1,126 @@
+package gcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+type ListResult struct {
+	Objects       []ListEntry
+	Prefixes      []string // "directories" rolled up by the delimiter, if any
+	NextPageToken string   // empty once the final page has been read, see ListWithPageToken
+}
+type ListEntry struct {
+	Name           string
+	Size           int64
+	Generation     int64
+	Metageneration int64
+	ETag           string
+	StorageClass   string
+	Updated        time.Time
+}
+
+// ParseListResponse reads the body of a LIST response, which may be either the XML API's <ListBucketResult>
+// document or the JSON API's "storage#objects" resource.
+func ParseListResponse(body io.Reader) (ListResult, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	if raw = bytes.TrimSpace(raw); bytes.HasPrefix(raw, []byte("{")) {
+		return parseListJSON(raw)
+	} else {
+		return parseListXML(raw)
+	}
+}
+
+func parseListXML(raw []byte) (result ListResult, err error) {
+	var parsed struct {
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+		NextMarker            string `xml:"NextMarker"`
+		Contents              []struct {
+			Key            string    `xml:"Key"`
+			Size           int64     `xml:"Size"`
+			Generation     int64     `xml:"Generation"`
+			MetaGeneration int64     `xml:"MetaGeneration"`
+			ETag           string    `xml:"ETag"`
+			StorageClass   string    `xml:"StorageClass"`
+			LastModified   time.Time `xml:"LastModified"`
+		} `xml:"Contents"`
+		CommonPrefixes []struct {
+			Prefix string `xml:"Prefix"`
+		} `xml:"CommonPrefixes"`
+	}
+	if err = xml.Unmarshal(raw, &parsed); err != nil {
+		return ListResult{}, ErrMalformedListing
+	}
+
+	for _, item := range parsed.Contents {
+		result.Objects = append(result.Objects, ListEntry{
+			Name:           item.Key,
+			Size:           item.Size,
+			Generation:     item.Generation,
+			Metageneration: item.MetaGeneration,
+			ETag:           item.ETag,
+			StorageClass:   item.StorageClass,
+			Updated:        item.LastModified,
+		})
+	}
+	for _, item := range parsed.CommonPrefixes {
+		result.Prefixes = append(result.Prefixes, item.Prefix)
+	}
+	if parsed.IsTruncated {
+		result.NextPageToken = parsed.NextContinuationToken
+		if len(result.NextPageToken) == 0 {
+			result.NextPageToken = parsed.NextMarker // V1 listing (no list-type=2)
+		}
+	}
+
+	return result, nil
+}
+func parseListJSON(raw []byte) (result ListResult, err error) {
+	var parsed struct {
+		NextPageToken string   `json:"nextPageToken"`
+		Prefixes      []string `json:"prefixes"`
+		Items         []struct {
+			Name           string    `json:"name"`
+			Size           string    `json:"size"` // the JSON API encodes 64-bit integers as strings
+			Generation     string    `json:"generation"`
+			Metageneration string    `json:"metageneration"`
+			ETag           string    `json:"etag"`
+			StorageClass   string    `json:"storageClass"`
+			Updated        time.Time `json:"updated"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(raw, &parsed); err != nil {
+		return ListResult{}, ErrMalformedListing
+	}
+
+	for _, item := range parsed.Items {
+		result.Objects = append(result.Objects, ListEntry{
+			Name:           item.Name,
+			Size:           parseInt64(item.Size),
+			Generation:     parseInt64(item.Generation),
+			Metageneration: parseInt64(item.Metageneration),
+			ETag:           item.ETag,
+			StorageClass:   item.StorageClass,
+			Updated:        item.Updated,
+		})
+	}
+	result.Prefixes = parsed.Prefixes
+	result.NextPageToken = parsed.NextPageToken
+	return result, nil
+}
+func parseInt64(value string) int64 {
+	parsed, _ := strconv.ParseInt(value, 10, 64)
+	return parsed
+}
+
+var ErrMalformedListing = errors.New("malformed object listing")
@@ -0,0 +1,93 @@
+package gcs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestParseListResponse_XML(t *testing.T) {
+	result, err := ParseListResponse(strings.NewReader(sampleListXML))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, result, should.Equal, ListResult{
+		Objects: []ListEntry{{
+			Name:           "folder/file.txt",
+			Size:           42,
+			Generation:     1700000000000001,
+			Metageneration: 2,
+			ETag:           `"etag"`,
+			StorageClass:   "STANDARD",
+			Updated:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}},
+		Prefixes:      []string{"folder/nested/"},
+		NextPageToken: "next-token",
+	})
+}
+func TestParseListResponse_JSON(t *testing.T) {
+	result, err := ParseListResponse(strings.NewReader(sampleListJSON))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, result, should.Equal, ListResult{
+		Objects: []ListEntry{{
+			Name:           "folder/file.txt",
+			Size:           42,
+			Generation:     1700000000000001,
+			Metageneration: 2,
+			ETag:           "CAE=",
+			StorageClass:   "STANDARD",
+			Updated:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}},
+		Prefixes:      []string{"folder/nested/"},
+		NextPageToken: "next-token",
+	})
+}
+func TestParseListResponse_FinalPage(t *testing.T) {
+	result, err := ParseListResponse(strings.NewReader(strings.Replace(sampleListXML, "true", "false", 1)))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, result.NextPageToken, should.Equal, "")
+}
+func TestParseListResponse_Malformed(t *testing.T) {
+	result, err := ParseListResponse(strings.NewReader(`{"items": [`))
+
+	should.So(t, err, should.Equal, ErrMalformedListing)
+	should.So(t, result.Objects, should.BeNil)
+}
+
+const sampleListXML = `<?xml version='1.0' encoding='UTF-8'?>
+<ListBucketResult xmlns="http://doc.s3.amazonaws.com/2006-03-01">
+  <Name>bucket</Name>
+  <Prefix>folder/</Prefix>
+  <IsTruncated>true</IsTruncated>
+  <NextContinuationToken>next-token</NextContinuationToken>
+  <Contents>
+    <Key>folder/file.txt</Key>
+    <Generation>1700000000000001</Generation>
+    <MetaGeneration>2</MetaGeneration>
+    <LastModified>2024-01-02T03:04:05.000Z</LastModified>
+    <ETag>"etag"</ETag>
+    <Size>42</Size>
+    <StorageClass>STANDARD</StorageClass>
+  </Contents>
+  <CommonPrefixes>
+    <Prefix>folder/nested/</Prefix>
+  </CommonPrefixes>
+</ListBucketResult>`
+
+const sampleListJSON = `{
+  "kind": "storage#objects",
+  "nextPageToken": "next-token",
+  "prefixes": ["folder/nested/"],
+  "items": [{
+    "name": "folder/file.txt",
+    "size": "42",
+    "generation": "1700000000000001",
+    "metageneration": "2",
+    "etag": "CAE=",
+    "storageClass": "STANDARD",
+    "updated": "2024-01-02T03:04:05.000Z"
+  }]
+}`
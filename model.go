@@ -9,25 +9,56 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type model struct {
-	context         context.Context
-	credentials     Credentials
-	method          string
-	host            string
-	scheme          string
-	bucket          string
-	resource        string
-	contentMD5      string
-	contentType     string
-	contentEncoding string
-	generation      string
-	etag            string
-	encryption      bool
-	contentLength   int64
-	content         io.Reader
+	context             context.Context
+	credentials         Credentials
+	method              string
+	host                string
+	scheme              string
+	bucket              string
+	resource            string
+	contentMD5          string
+	contentType         string
+	contentEncoding     string
+	contentDisposition  string
+	contentLanguage     string
+	cacheControl        string
+	storageClass        string
+	metadata            map[string]string
+	ifGenerationMatch   string
+	ifGenerationNot     string
+	ifMetageneration    string
+	ifMetagenerationNot string
+	ifMatch             string
+	ifModifiedSince     time.Time
+	etag                string
+	objectGeneration    string
+	ranged              bool // see GetWithRange
+	rangeOffset         int64
+	rangeLength         int64
+	encryption          bool
+	contentLength       int64
+	content             io.Reader
+	signingVersion      SigningVersion
+	signedAt            time.Time
+	expiration          time.Time
+	listPrefix          string
+	listDelimiter       string
+	listMaxResults      int
+	listStartOffset     string
+	listPageToken       string
+	chunkSize           int
+	sessionURL          string
+	resumable           bool // initiates a resumable upload session (see ResumableUpload)
+	downloadRangeSize   int64
+	downloadConcurrency int
+	downloadRetry       []retryOption
 
 	// fields are computed during and after options are applied.
 	objectKey string
@@ -36,15 +67,16 @@ type model struct {
 }
 
 func newModel(method string, options []Option) model {
-	this := &model{method: method}
+	this := &model{method: method, signedAt: time.Now().UTC()}
 
 	WithEndpoint(defaultScheme, defaultHost)(this)
 	WithSignedExpiration(defaultExpiration())(this)
 	WithContext(context.Background())(this)
+	WithSigningVersion(V2)(this)
 
 	this.applyOptions(options)
 	this.objectKey = path.Join("/", this.bucket, this.resource)
-	this.targetURL = &url.URL{Scheme: this.scheme, Host: this.host, Path: this.objectKey}
+	this.targetURL = &url.URL{Scheme: this.scheme, Host: this.host, Path: this.objectKey, RawQuery: this.buildQuery().Encode()}
 
 	return *this
 }
@@ -56,14 +88,47 @@ func (this *model) applyOptions(options []Option) {
 		}
 	}
 }
+func (this *model) buildQuery() url.Values {
+	query := url.Values{}
+	if this.method != LIST {
+		// addresses a specific (e.g. noncurrent) version of the object rather than the live one
+		versioned := this.method == GET || this.method == HEAD || this.method == DELETE
+		tryAppendQuery(versioned && len(this.objectGeneration) > 0, query, queryGeneration, this.objectGeneration)
+		return query
+	}
+
+	query.Set(queryListType, "2")
+	tryAppendQuery(len(this.listPrefix) > 0, query, queryPrefix, this.listPrefix)
+	tryAppendQuery(len(this.listDelimiter) > 0, query, queryDelimiter, this.listDelimiter)
+	tryAppendQuery(this.listMaxResults > 0, query, queryMaxKeys, strconv.Itoa(this.listMaxResults))
+	tryAppendQuery(len(this.listStartOffset) > 0, query, queryStartAfter, this.listStartOffset)
+	tryAppendQuery(len(this.listPageToken) > 0, query, queryContinuationToken, this.listPageToken)
+	return query
+}
+func tryAppendQuery(condition bool, query url.Values, name, value string) {
+	if condition {
+		query.Set(name, value)
+	}
+}
+
+// httpMethod translates pseudo-methods (e.g. LIST) into the HTTP verb sent on the wire (and signed).
+func (this *model) httpMethod() string {
+	if this.method == LIST {
+		return GET
+	}
+	return this.method
+}
+
 func (this *model) validate() error {
 	if len(this.method) == 0 {
 		return ErrHTTPMethodMissing
-	} else if this.method != GET && this.method != PUT && this.method != HEAD {
+	} else if this.method != GET && this.method != PUT && this.method != HEAD && this.method != DELETE && this.method != LIST {
 		return ErrHTTPMethodUnrecognized
+	} else if this.signingVersion != V2 && this.signingVersion != V4 {
+		return ErrSigningVersionUnrecognized
 	} else if len(this.bucket) == 0 {
 		return ErrBucketMissing
-	} else if len(this.resource) == 0 {
+	} else if len(this.resource) == 0 && this.method != LIST {
 		return ErrResourceMissing
 	} else if this.method == PUT && this.content == nil {
 		return ErrContentMissing
@@ -72,25 +137,32 @@ func (this *model) validate() error {
 }
 
 func (this *model) buildRequest() (request *http.Request, err error) {
-	if request, err = http.NewRequest(this.method, this.targetURL.String(), this.content); err != nil {
+	if request, err = http.NewRequest(this.httpMethod(), this.targetURL.String(), this.content); err != nil {
 		return nil, err
 	}
 
+	request.ContentLength = this.contentLength
+	this.appendHeaders(request) // headers must be in place before signing (V4 signs them)
+
 	if err = this.authorizeRequest(request); err != nil {
 		return nil, err
 	}
 
-	request.ContentLength = this.contentLength
-	this.appendHeaders(request)
 	return request.WithContext(this.context), nil
 }
 func (this *model) authorizeRequest(request *http.Request) error {
-	if len(this.credentials.BearerToken) > 0 {
-		request.Header.Set("Authorization", this.credentials.BearerToken)
+	if bearerToken, err := this.credentials.bearerToken(this.context); err != nil {
+		return err
+	} else if len(bearerToken) > 0 {
+		request.Header.Set("Authorization", bearerToken)
 		return nil
 	}
 
-	signature, err := this.calculateSignature()
+	if this.signingVersion == V4 {
+		return this.authorizeRequestV4(request)
+	}
+
+	signature, err := this.calculateSignature(request.Header)
 	if err != nil {
 		return err
 	}
@@ -98,23 +170,53 @@ func (this *model) authorizeRequest(request *http.Request) error {
 	request.URL = this.buildSignedURL(signature)
 	return nil
 }
-func (this *model) calculateSignature() (string, error) {
+func (this *model) calculateSignature(headers http.Header) (string, error) {
 	buffer := bytes.NewBuffer(nil)
-	this.appendToBuffer(buffer)
+	this.appendToBuffer(buffer, headers)
 
-	if signed, err := this.credentials.PrivateKey.Sign(buffer.Bytes()); err != nil {
+	if signed, err := this.credentials.signer().Sign(this.context, buffer.Bytes()); err != nil {
 		return "", err
 	} else {
 		return base64.StdEncoding.EncodeToString(signed), nil
 	}
 }
-func (this *model) appendToBuffer(buffer io.Writer) {
+func (this *model) appendToBuffer(buffer io.Writer, headers http.Header) {
 	// https://cloud.google.com/storage/docs/access-control/signed-urls
 	// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
-	appendTo(buffer, "%s\n%s\n%s\n%s\n", this.method, this.contentMD5, this.contentType, this.epoch)
-	appendIf(len(this.generation) > 0 && this.method == PUT, buffer, "%s:%s\n", headerGeneration, this.generation)
+	appendTo(buffer, "%s\n%s\n%s\n%s\n", this.httpMethod(), this.contentMD5, this.contentType, this.epoch)
+	appendExtensionHeaders(buffer, headers)
 	appendTo(buffer, "%s", this.objectKey)
 }
+
+// appendExtensionHeaders writes the canonical form of each x-goog-* request header: the lower-case name and the
+// comma-separated values (with whitespace folded), ordered by name.
+func appendExtensionHeaders(buffer io.Writer, headers http.Header) {
+	values := make(map[string]string)
+	for name, items := range headers {
+		if name = strings.ToLower(name); !strings.HasPrefix(name, extensionHeaderPrefix) {
+			continue
+		}
+
+		values[name] = foldHeaderValues(items)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		appendTo(buffer, "%s:%s\n", name, values[name])
+	}
+}
+func foldHeaderValues(items []string) string {
+	trimmed := make([]string, 0, len(items))
+	for _, item := range items {
+		trimmed = append(trimmed, strings.Join(strings.Fields(item), " "))
+	}
+	return strings.Join(trimmed, ",")
+}
 func appendIf(condition bool, writer io.Writer, format string, values ...interface{}) {
 	if condition {
 		appendTo(writer, format, values...)
@@ -136,16 +238,40 @@ func (this *model) buildSignedURL(signature string) *url.URL {
 }
 func (this *model) appendHeaders(request *http.Request) {
 	headers := request.Header
-
+	if this.method != LIST {
+		this.appendPreconditions(headers)
+	}
 	if this.method == GET {
-		tryAppendHeaders(len(this.etag) > 0, headers, headerIfNoneMatch, this.etag)
-	} else if this.method == PUT {
+		tryAppendHeaders(this.ranged, headers, headerRange, formatRange(this.rangeOffset, this.rangeLength))
+	}
+
+	if this.method == PUT || this.resumable {
 		tryAppendHeaders(len(this.contentType) > 0, headers, headerContentType, this.contentType)
 		tryAppendHeaders(len(this.contentMD5) > 0, headers, headerContentMD5, this.contentMD5)
 		tryAppendHeaders(len(this.contentEncoding) > 0, headers, headerContentEncoding, this.contentEncoding)
-		tryAppendHeaders(len(this.generation) > 0, headers, headerGeneration, this.generation)
+		tryAppendHeaders(len(this.contentDisposition) > 0, headers, headerContentDisposition, this.contentDisposition)
+		tryAppendHeaders(len(this.contentLanguage) > 0, headers, headerContentLanguage, this.contentLanguage)
+		tryAppendHeaders(len(this.cacheControl) > 0, headers, headerCacheControl, this.cacheControl)
+		tryAppendHeaders(len(this.storageClass) > 0, headers, headerStorageClass, this.storageClass)
+		tryAppendHeaders(this.resumable, headers, headerResumable, resumableStart)
+		for key, value := range this.metadata {
+			headers.Set(headerMetadataPrefix+key, value)
+		}
 	}
 }
+
+// appendPreconditions sets the conditions under which the service carries out the request; the x-goog-* conditions
+// are also part of the V2 string-to-sign (see appendExtensionHeaders) while the standard ones are not.
+// https://cloud.google.com/storage/docs/xml-api/reference-headers
+func (this *model) appendPreconditions(headers http.Header) {
+	tryAppendHeaders(len(this.ifGenerationMatch) > 0, headers, headerGeneration, this.ifGenerationMatch)
+	tryAppendHeaders(len(this.ifGenerationNot) > 0, headers, headerGenerationNot, this.ifGenerationNot)
+	tryAppendHeaders(len(this.ifMetageneration) > 0, headers, headerMetageneration, this.ifMetageneration)
+	tryAppendHeaders(len(this.ifMetagenerationNot) > 0, headers, headerMetagenerationNot, this.ifMetagenerationNot)
+	tryAppendHeaders(len(this.ifMatch) > 0, headers, headerIfMatch, this.ifMatch)
+	tryAppendHeaders(!this.ifModifiedSince.IsZero(), headers, headerIfModifiedSince, this.ifModifiedSince.UTC().Format(http.TimeFormat))
+	tryAppendHeaders(len(this.etag) > 0 && (this.method == GET || this.method == HEAD), headers, headerIfNoneMatch, this.etag)
+}
 func tryAppendHeaders(condition bool, headers http.Header, name, value string) {
 	if condition {
 		headers.Set(name, value)
@@ -155,16 +281,34 @@ func tryAppendHeaders(condition bool, headers http.Header, name, value string) {
 func defaultExpiration() time.Time { return time.Now().UTC().Add(defaultExpireTime) }
 
 const (
-	defaultScheme         = "https"
-	defaultHost           = "storage.googleapis.com"
-	headerContentType     = "Content-Type"
-	headerContentMD5      = "Content-MD5"
-	headerContentEncoding = "Content-Encoding"
-	headerIfNoneMatch     = "If-None-Match"
-	headerGeneration      = "x-goog-if-generation-match"
-	queryAccessID         = "GoogleAccessId"
-	queryExpires          = "Expires"
-	querySignature        = "Signature"
+	defaultScheme            = "https"
+	defaultHost              = "storage.googleapis.com"
+	headerContentType        = "Content-Type"
+	headerContentMD5         = "Content-MD5"
+	headerContentEncoding    = "Content-Encoding"
+	headerContentDisposition = "Content-Disposition"
+	headerContentLanguage    = "Content-Language"
+	headerIfNoneMatch        = "If-None-Match"
+	headerIfMatch            = "If-Match"
+	headerIfModifiedSince    = "If-Modified-Since"
+	headerGeneration         = "x-goog-if-generation-match"
+	headerGenerationNot      = "x-goog-if-generation-not-match"
+	headerMetageneration     = "x-goog-if-metageneration-match"
+	headerMetagenerationNot  = "x-goog-if-metageneration-not-match"
+	headerResumable          = "x-goog-resumable"
+	extensionHeaderPrefix    = "x-goog-"
+	resumableStart           = "start"
+	queryAccessID            = "GoogleAccessId"
+	queryExpires             = "Expires"
+	querySignature           = "Signature"
+
+	queryGeneration        = "generation"
+	queryListType          = "list-type"
+	queryPrefix            = "prefix"
+	queryDelimiter         = "delimiter"
+	queryMaxKeys           = "max-keys"
+	queryStartAfter        = "start-after"
+	queryContinuationToken = "continuation-token"
 )
 
 var defaultExpireTime = time.Second * 30
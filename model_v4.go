@@ -0,0 +1,133 @@
+package gcs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// https://cloud.google.com/storage/docs/access-control/signing-urls-manually
+// https://cloud.google.com/storage/docs/authentication/canonical-requests
+func (this *model) authorizeRequestV4(request *http.Request) error {
+	expires := math.Round(this.expiration.Sub(this.signedAt).Seconds())
+	if expires > maxExpiresV4 {
+		return ErrSignedExpirationTooLong
+	} else if expires < 1 {
+		return ErrSignedExpirationTooShort // e.g. already in the past
+	}
+
+	date := this.signedAt.UTC().Format(formatDateV4)
+	timestamp := this.signedAt.UTC().Format(formatTimestampV4)
+	scope := date + "/" + scopeSuffixV4
+	headers, signedHeaders := this.canonicalHeadersV4(request.Header)
+
+	query := this.targetURL.Query()
+	query.Set(queryAlgorithmV4, algorithmV4)
+	query.Set(queryCredentialV4, this.credentials.AccessID+"/"+scope)
+	query.Set(queryDateV4, timestamp)
+	query.Set(queryExpiresV4, strconv.FormatInt(int64(expires), 10))
+	query.Set(querySignedHeadersV4, signedHeaders)
+
+	canonicalPath := escapeV4(this.objectKey, true)
+	canonicalQuery := canonicalQueryV4(query)
+
+	buffer := bytes.NewBuffer(nil)
+	appendCanonicalRequestV4(buffer, this.httpMethod(), canonicalPath, canonicalQuery, headers, signedHeaders)
+	hashed := sha256.Sum256(buffer.Bytes())
+
+	buffer.Reset()
+	appendTo(buffer, "%s\n%s\n%s\n%s", algorithmV4, timestamp, scope, hex.EncodeToString(hashed[:]))
+
+	signed, err := this.credentials.signer().Sign(this.context, buffer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	target := *this.targetURL
+	target.RawPath = canonicalPath
+	target.RawQuery = canonicalQuery + "&" + querySignatureV4 + "=" + hex.EncodeToString(signed)
+	request.URL = &target
+	return nil
+}
+func appendCanonicalRequestV4(buffer io.Writer, method, path, query, headers, signedHeaders string) {
+	appendTo(buffer, "%s\n%s\n%s\n%s\n%s\n%s", method, path, query, headers, signedHeaders, unsignedPayloadV4)
+}
+
+// canonicalHeadersV4 returns the canonical header block (each line terminated by a newline) and the
+// semicolon-separated list of signed header names; the host header is always signed.
+func (this *model) canonicalHeadersV4(headers http.Header) (string, string) {
+	values := map[string]string{"host": this.targetURL.Host}
+	for name, items := range headers {
+		values[strings.ToLower(name)] = foldHeaderValues(items)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	builder := strings.Builder{}
+	for _, name := range names {
+		appendTo(&builder, "%s:%s\n", name, values[name])
+	}
+
+	return builder.String(), strings.Join(names, ";")
+}
+func canonicalQueryV4(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, escapeV4(name, false)+"="+escapeV4(value, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// escapeV4 percent-encodes everything other than the RFC 3986 unreserved characters (and, optionally, slashes).
+func escapeV4(value string, keepSlash bool) string {
+	builder := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if character := value[i]; isUnreservedV4(character) || (keepSlash && character == '/') {
+			builder.WriteByte(character)
+		} else {
+			appendTo(&builder, "%%%02X", character)
+		}
+	}
+	return builder.String()
+}
+func isUnreservedV4(value byte) bool {
+	return ('A' <= value && value <= 'Z') || ('a' <= value && value <= 'z') || ('0' <= value && value <= '9') ||
+		value == '-' || value == '.' || value == '_' || value == '~'
+}
+
+const (
+	algorithmV4          = "GOOG4-RSA-SHA256"
+	scopeSuffixV4        = "auto/storage/goog4_request"
+	unsignedPayloadV4    = "UNSIGNED-PAYLOAD"
+	formatDateV4         = "20060102"
+	formatTimestampV4    = "20060102T150405Z"
+	maxExpiresV4         = 7 * 24 * 60 * 60
+	queryAlgorithmV4     = "X-Goog-Algorithm"
+	queryCredentialV4    = "X-Goog-Credential"
+	queryDateV4          = "X-Goog-Date"
+	queryExpiresV4       = "X-Goog-Expires"
+	querySignedHeadersV4 = "X-Goog-SignedHeaders"
+	querySignatureV4     = "X-Goog-Signature"
+)
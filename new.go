@@ -15,9 +15,18 @@ func NewRequest(method string, options ...Option) (*http.Request, error) {
 }
 
 const (
-	GET  = "GET"
-	PUT  = "PUT"
-	HEAD = "HEAD"
+	GET    = "GET"
+	PUT    = "PUT"
+	HEAD   = "HEAD"
+	DELETE = "DELETE"
+	LIST   = "LIST" // sent as a GET against the bucket, see ListWith... options and ParseListResponse
+)
+
+type SigningVersion int
+
+const (
+	V2 SigningVersion = 2 // legacy "GoogleAccessId/Expires/Signature" query string
+	V4 SigningVersion = 4 // GOOG4-RSA-SHA256
 )
 
 var (
@@ -26,4 +35,8 @@ var (
 	ErrBucketMissing          = errors.New("bucket is required")
 	ErrResourceMissing        = errors.New("object resource key is required")
 	ErrContentMissing         = errors.New("content payload is required")
+
+	ErrSigningVersionUnrecognized = errors.New("unrecognized signing version")
+	ErrSignedExpirationTooLong    = errors.New("V4 signed expiration may not exceed seven days")
+	ErrSignedExpirationTooShort   = errors.New("V4 signed expiration must be at least one second in the future")
 )
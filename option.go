@@ -34,7 +34,10 @@ func WithExpiration(value time.Time) Option {
 	return WithSignedExpiration(value)
 }
 func WithSignedExpiration(value time.Time) Option {
-	return func(this *model) { this.epoch = strconv.FormatInt(value.Unix(), 10) }
+	return func(this *model) { this.expiration = value; this.epoch = strconv.FormatInt(value.Unix(), 10) }
+}
+func WithSigningVersion(value SigningVersion) Option {
+	return func(this *model) { this.signingVersion = value }
 }
 
 func WithContext(value context.Context) Option {
@@ -44,8 +47,73 @@ func WithContext(value context.Context) Option {
 func GetWithETag(value string) Option {
 	return func(this *model) { this.etag = strings.TrimSpace(value) }
 }
+
+// GetWithGeneration addresses the given generation of the object (e.g. a noncurrent version in a bucket with object
+// versioning enabled) rather than the live one; it applies to GET, HEAD and DELETE.
+func GetWithGeneration(value string) Option {
+	return func(this *model) { this.objectGeneration = strings.TrimSpace(value) }
+}
+
+// GetWithRange requests length bytes of the object beginning at offset (reported by Object.Range). A length of zero
+// reads through the end of the object while a negative offset requests a suffix range, i.e. the last -offset bytes
+// of the object, in which case the length is ignored.
+func GetWithRange(offset, length int64) Option {
+	return func(this *model) { this.ranged = true; this.rangeOffset = offset; this.rangeLength = length }
+}
 func PutWithGeneration(value string) Option {
-	return func(this *model) { this.generation = strings.TrimSpace(value) }
+	return WithIfGenerationMatch(value)
+}
+func DeleteWithGeneration(value string) Option {
+	return WithIfGenerationMatch(value)
+}
+func DeleteWithMetageneration(value string) Option {
+	return WithIfMetagenerationMatch(value)
+}
+
+// WithIfGenerationMatch only carries out the request (GET, HEAD, PUT or DELETE) when the live generation of the
+// object matches the value provided; "0" requires that the object not yet exist.
+func WithIfGenerationMatch(value string) Option {
+	return func(this *model) { this.ifGenerationMatch = strings.TrimSpace(value) }
+}
+
+// WithIfGenerationNotMatch only carries out the request when the live generation of the object differs from the
+// value provided.
+func WithIfGenerationNotMatch(value string) Option {
+	return func(this *model) { this.ifGenerationNot = strings.TrimSpace(value) }
+}
+func WithIfMetagenerationMatch(value string) Option {
+	return func(this *model) { this.ifMetageneration = strings.TrimSpace(value) }
+}
+func WithIfMetagenerationNotMatch(value string) Option {
+	return func(this *model) { this.ifMetagenerationNot = strings.TrimSpace(value) }
+}
+
+// WithIfMatch only carries out the request when the ETag of the object matches the value provided.
+func WithIfMatch(value string) Option {
+	return func(this *model) { this.ifMatch = strings.TrimSpace(value) }
+}
+
+// WithIfModifiedSince only carries out the request when the object has been modified after the time provided.
+func WithIfModifiedSince(value time.Time) Option {
+	return func(this *model) { this.ifModifiedSince = value }
+}
+
+func ListWithPrefix(value string) Option {
+	return func(this *model) { this.listPrefix = value }
+}
+func ListWithDelimiter(value string) Option {
+	return func(this *model) { this.listDelimiter = value }
+}
+func ListWithMaxResults(value int) Option {
+	return func(this *model) { this.listMaxResults = value }
+}
+
+// ListWithStartOffset only lists objects whose names sort lexicographically after the value provided (exclusive).
+func ListWithStartOffset(value string) Option {
+	return func(this *model) { this.listStartOffset = value }
+}
+func ListWithPageToken(value string) Option {
+	return func(this *model) { this.listPageToken = strings.TrimSpace(value) }
 }
 
 func PutWithContentString(value string) Option {
@@ -73,6 +141,58 @@ func PutWithContentMD5(value []byte) Option {
 func PutWithContentEncoding(value string) Option {
 	return func(this *model) { this.contentEncoding = value }
 }
+func PutWithContentDisposition(value string) Option {
+	return func(this *model) { this.contentDisposition = strings.TrimSpace(value) }
+}
+func PutWithContentLanguage(value string) Option {
+	return func(this *model) { this.contentLanguage = strings.TrimSpace(value) }
+}
+func PutWithCacheControl(value string) Option {
+	return func(this *model) { this.cacheControl = strings.TrimSpace(value) }
+}
+
+// PutWithStorageClass sets the storage class of the object (e.g. "NEARLINE") rather than the bucket's default.
+func PutWithStorageClass(value string) Option {
+	return func(this *model) { this.storageClass = strings.TrimSpace(value) }
+}
+
+// PutWithMetadata adds a custom metadata entry (sent as x-goog-meta-{key}); it may be provided more than once.
+func PutWithMetadata(key, value string) Option {
+	return func(this *model) {
+		if this.metadata == nil {
+			this.metadata = make(map[string]string)
+		}
+		this.metadata[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+}
+
+// ResumableWithChunkSize sets the number of bytes sent per request by a ResumableUpload; it is rounded up to a
+// multiple of 256 KiB as required by the service.
+func ResumableWithChunkSize(value int) Option {
+	return func(this *model) { this.chunkSize = value }
+}
+
+// ResumableWithSession continues a previously initiated session (see ResumableUpload.SessionURL) rather than
+// starting a new one.
+func ResumableWithSession(value string) Option {
+	return func(this *model) { this.sessionURL = strings.TrimSpace(value) }
+}
+
+// DownloadWithRangeSize sets the number of bytes requested per range by a RangedDownload.
+func DownloadWithRangeSize(value int64) Option {
+	return func(this *model) { this.downloadRangeSize = value }
+}
+
+// DownloadWithConcurrency sets the number of ranges a RangedDownload requests at the same time.
+func DownloadWithConcurrency(value int) Option {
+	return func(this *model) { this.downloadConcurrency = value }
+}
+
+// DownloadWithRetry configures how each range of a RangedDownload is retried (e.g. RetryOptions.MaxAttempts); the
+// defaults are those of NewRetryClient.
+func DownloadWithRetry(options ...retryOption) Option {
+	return func(this *model) { this.downloadRetry = options }
+}
 
 func WithCompositeOption(options ...Option) Option {
 	return func(this *model) { this.applyOptions(options) }
@@ -2,6 +2,11 @@ package gcs
 
 import (
 	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"io"
 	"strconv"
 	"strings"
@@ -191,6 +196,95 @@ func TestPUT_Generation(t *testing.T) {
 	should.So(t, request.Header.Get("x-goog-if-generation-match"), should.Equal, "42")
 }
 
+func TestPUT_MetadataAndCaching(t *testing.T) {
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"),
+		PutWithMetadata("Build-Number", "42"), PutWithMetadata("origin", "ci"),
+		PutWithCacheControl("public, max-age=3600"), PutWithContentDisposition(`attachment; filename="file.txt"`),
+		PutWithContentLanguage("en"), PutWithStorageClass("NEARLINE"))
+
+	should.So(t, request.Header.Get("x-goog-meta-build-number"), should.Equal, "42")
+	should.So(t, request.Header.Get("x-goog-meta-origin"), should.Equal, "ci")
+	should.So(t, request.Header.Get("Cache-Control"), should.Equal, "public, max-age=3600")
+	should.So(t, request.Header.Get("Content-Disposition"), should.Equal, `attachment; filename="file.txt"`)
+	should.So(t, request.Header.Get("Content-Language"), should.Equal, "en")
+	should.So(t, request.Header.Get("x-goog-storage-class"), should.Equal, "NEARLINE")
+}
+
+func TestGET_MetadataIgnored(t *testing.T) {
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), PutWithMetadata("origin", "ci"))
+
+	should.So(t, request.Header.Get("x-goog-meta-origin"), should.Equal, "")
+}
+
+func TestPUT_WithCredentials_ExtensionHeaders(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	frozen := time.Unix(1554410829, 0)
+
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(frozen),
+		PutWithContentString("content"), PutWithContentType("text/plain"), PutWithGeneration("0"),
+		PutWithMetadata("origin", "  ci   pipeline "), PutWithMetadata("build", "42"),
+		PutWithStorageClass("NEARLINE"), PutWithCacheControl("no-cache"))
+
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), ""+
+		"PUT\n"+
+		"\n"+
+		"text/plain\n"+
+		"1554410829\n"+
+		"x-goog-if-generation-match:0\n"+
+		"x-goog-meta-build:42\n"+
+		"x-goog-meta-origin:ci pipeline\n"+
+		"x-goog-storage-class:NEARLINE\n"+
+		"/bucket/file.txt")
+}
+
+func TestPreconditions(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	for _, method := range []string{GET, HEAD, PUT, DELETE} {
+		request, err := NewRequest(method, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"),
+			WithIfGenerationMatch("42"), WithIfGenerationNotMatch("41"),
+			WithIfMetagenerationMatch("7"), WithIfMetagenerationNotMatch("6"),
+			WithIfMatch(`"etag"`), WithIfModifiedSince(modified))
+
+		should.So(t, err, should.BeNil)
+		should.So(t, request.Header.Get("x-goog-if-generation-match"), should.Equal, "42")
+		should.So(t, request.Header.Get("x-goog-if-generation-not-match"), should.Equal, "41")
+		should.So(t, request.Header.Get("x-goog-if-metageneration-match"), should.Equal, "7")
+		should.So(t, request.Header.Get("x-goog-if-metageneration-not-match"), should.Equal, "6")
+		should.So(t, request.Header.Get("If-Match"), should.Equal, `"etag"`)
+		should.So(t, request.Header.Get("If-Modified-Since"), should.Equal, "Tue, 02 Jan 2024 02:04:05 GMT")
+	}
+}
+
+func TestLIST_PreconditionsIgnored(t *testing.T) {
+	request, _ := NewRequest(LIST, WithBucket("bucket"), WithIfGenerationMatch("42"), WithIfMatch("etag"))
+
+	should.So(t, request.Header.Get("x-goog-if-generation-match"), should.Equal, "")
+	should.So(t, request.Header.Get("If-Match"), should.Equal, "")
+}
+
+func TestHEAD_WithCredentials_Preconditions(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	frozen := time.Unix(1554410829, 0)
+
+	request, _ := NewRequest(HEAD, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(frozen),
+		WithIfMetagenerationNotMatch("6"), WithIfGenerationNotMatch("41"), WithIfGenerationMatch("42"),
+		WithIfMetagenerationMatch("7"), WithIfMatch("etag"), WithIfModifiedSince(frozen), GetWithETag("other"))
+
+	should.So(t, request.Header.Get("If-None-Match"), should.Equal, "other")
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), ""+
+		"HEAD\n"+
+		"\n"+
+		"\n"+
+		"1554410829\n"+
+		"x-goog-if-generation-match:42\n"+
+		"x-goog-if-generation-not-match:41\n"+
+		"x-goog-if-metageneration-match:7\n"+
+		"x-goog-if-metageneration-not-match:6\n"+
+		"/bucket/file.txt")
+}
+
 func TestGET_WithCredentials(t *testing.T) {
 	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
 	credentials.PrivateKey.random = nil // make it deterministic so the signature doesn't change between test runs
@@ -217,3 +311,235 @@ func TestPUT_WithCredentials(t *testing.T) {
 
 	should.So(t, request.URL.Query().Get("Signature"), should.Equal, "VHcBMifvvm1Vg1rbaoXbOs3a2IbMBBx/LInfjRD/lxgA4njeFS7K1CIYHcTlVZNrJFB0vWo8/424wTcgh0WvMRHCsJgN0jm48jjRsASazKriGzO3Y86COcdbpG8Ifs0565ahC0cHY7+/U6TT7W4N11XNYEh6WU+MlMDrFAaPCCUOeHaUwcz6NAUDF5cZQdXAOYQrtFhi2ODGzZ9Y/rlUNiEdXWdIx46+gIWNkYXP6JsIRDHnZGAcZPUhzF6r6YyPMto/MhwKCjx4kxR/jSp2hDa8TAfVULXBTAlqxbWbTpDvht8XcZPx6/T/TnYcZHhKyIQIWCvQzIrrJLCX8rmVpA==")
 }
+
+func TestUnrecognizedSigningVersion(t *testing.T) {
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithSigningVersion(3))
+
+	should.So(t, err, should.Equal, ErrSigningVersionUnrecognized)
+	should.So(t, request, should.BeNil)
+}
+
+func TestV4_ExpirationTooLong(t *testing.T) {
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithSigningVersion(V4), WithSignedExpiration(time.Now().Add(time.Hour*24*8)))
+
+	should.So(t, err, should.Equal, ErrSignedExpirationTooLong)
+	should.So(t, request, should.BeNil)
+}
+
+func TestV4_ExpirationInPast(t *testing.T) {
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithSigningVersion(V4), WithSignedExpiration(time.Now().Add(-time.Minute)))
+
+	should.So(t, err, should.Equal, ErrSignedExpirationTooShort)
+	should.So(t, request, should.BeNil)
+}
+
+func TestV4_ExpirationUnderOneSecond(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	signedAt := time.Unix(1554410829, 0).UTC()
+	input := newModel(GET, []Option{WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSigningVersion(V4), WithSignedExpiration(signedAt.Add(time.Millisecond * 400))})
+	input.signedAt = signedAt
+
+	request, err := input.buildRequest()
+
+	should.So(t, err, should.Equal, ErrSignedExpirationTooShort)
+	should.So(t, request, should.BeNil)
+}
+
+func TestGET_WithCredentialsV4(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	signedAt := time.Unix(1554410829, 0).UTC()
+	input := newModel(GET, []Option{WithBucket("bucket"), WithResource("folder/file name.txt"),
+		WithCredentials(credentials), WithSigningVersion(V4), WithSignedExpiration(signedAt.Add(time.Minute))})
+	input.signedAt = signedAt
+
+	request, err := input.buildRequest()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.URL.EscapedPath(), should.Equal, "/bucket/folder/file%20name.txt")
+	query := request.URL.Query()
+	should.So(t, query.Get("X-Goog-Algorithm"), should.Equal, "GOOG4-RSA-SHA256")
+	should.So(t, query.Get("X-Goog-Credential"), should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com/20190404/auto/storage/goog4_request")
+	should.So(t, query.Get("X-Goog-Date"), should.Equal, "20190404T204709Z")
+	should.So(t, query.Get("X-Goog-Expires"), should.Equal, "60")
+	should.So(t, query.Get("X-Goog-SignedHeaders"), should.Equal, "host")
+	should.So(t, query.Get("GoogleAccessId"), should.Equal, "")
+	assertSignatureV4(t, credentials, query.Get("X-Goog-Signature"), "20190404T204709Z", ""+
+		"GET\n"+
+		"/bucket/folder/file%20name.txt\n"+
+		"X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Credential=sample-key%40project-id-here.iam.gserviceaccount.com%2F20190404%2Fauto%2Fstorage%2Fgoog4_request&X-Goog-Date=20190404T204709Z&X-Goog-Expires=60&X-Goog-SignedHeaders=host\n"+
+		"host:storage.googleapis.com\n"+
+		"\n"+
+		"host\n"+
+		"UNSIGNED-PAYLOAD")
+}
+
+func TestPUT_WithCredentialsV4(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	signedAt := time.Unix(1554410829, 0).UTC()
+	input := newModel(PUT, []Option{WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSigningVersion(V4), WithSignedExpiration(signedAt.Add(time.Minute)),
+		PutWithContentString("content"), PutWithContentType("text/plain"), PutWithGeneration("42")})
+	input.signedAt = signedAt
+
+	request, err := input.buildRequest()
+
+	should.So(t, err, should.BeNil)
+	query := request.URL.Query()
+	should.So(t, query.Get("X-Goog-SignedHeaders"), should.Equal, "content-type;host;x-goog-if-generation-match")
+	assertSignatureV4(t, credentials, query.Get("X-Goog-Signature"), "20190404T204709Z", ""+
+		"PUT\n"+
+		"/bucket/file.txt\n"+
+		"X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Credential=sample-key%40project-id-here.iam.gserviceaccount.com%2F20190404%2Fauto%2Fstorage%2Fgoog4_request&X-Goog-Date=20190404T204709Z&X-Goog-Expires=60&X-Goog-SignedHeaders=content-type%3Bhost%3Bx-goog-if-generation-match\n"+
+		"content-type:text/plain\n"+
+		"host:storage.googleapis.com\n"+
+		"x-goog-if-generation-match:42\n"+
+		"\n"+
+		"content-type;host;x-goog-if-generation-match\n"+
+		"UNSIGNED-PAYLOAD")
+}
+
+func assertSignatureV4(t *testing.T, credentials Credentials, signature, timestamp, canonicalRequest string) {
+	t.Helper()
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "GOOG4-RSA-SHA256\n" + timestamp + "\n" + timestamp[:8] + "/auto/storage/goog4_request\n" + hex.EncodeToString(hashed[:])
+	digest := sha256.Sum256([]byte(stringToSign))
+	decoded, _ := hex.DecodeString(signature)
+
+	err := rsa.VerifyPKCS1v15(&credentials.PrivateKey.inner.PublicKey, crypto.SHA256, digest[:], decoded)
+
+	should.So(t, err, should.BeNil)
+}
+
+func TestGeneration(t *testing.T) {
+	for _, method := range []string{GET, HEAD, DELETE} {
+		request, err := NewRequest(method, WithBucket("bucket"), WithResource("file.txt"), GetWithGeneration(" 1700000000000000 "))
+
+		should.So(t, err, should.BeNil)
+		should.So(t, request.URL.Path, should.Equal, "/bucket/file.txt")
+		should.So(t, request.URL.Query().Get("generation"), should.Equal, "1700000000000000")
+	}
+}
+
+func TestPUT_GenerationQueryIgnored(t *testing.T) {
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"),
+		GetWithGeneration("1700000000000000"))
+
+	should.So(t, request.URL.Query().Has("generation"), should.BeFalse)
+}
+
+func TestGeneration_WithCredentials(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	frozen := time.Unix(1554410829, 0)
+
+	for _, method := range []string{GET, HEAD, DELETE} {
+		request, _ := NewRequest(method, WithBucket("bucket"), WithResource("file.txt"),
+			WithCredentials(credentials), WithSignedExpiration(frozen), GetWithGeneration("1700000000000000"))
+
+		query := request.URL.Query()
+		should.So(t, query.Get("generation"), should.Equal, "1700000000000000")
+		should.So(t, query.Get("GoogleAccessId"), should.Equal, credentials.AccessID)
+		should.So(t, query.Get("Expires"), should.Equal, "1554410829")
+		assertSignatureV2(t, credentials, query.Get("Signature"), method+"\n\n\n1554410829\n/bucket/file.txt")
+	}
+}
+
+func TestGeneration_WithCredentialsV4(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	signedAt := time.Unix(1554410829, 0).UTC()
+
+	for _, method := range []string{GET, HEAD, DELETE} {
+		input := newModel(method, []Option{WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials),
+			WithSigningVersion(V4), WithSignedExpiration(signedAt.Add(time.Minute)), GetWithGeneration("1700000000000000")})
+		input.signedAt = signedAt
+
+		request, err := input.buildRequest()
+
+		should.So(t, err, should.BeNil)
+		query := request.URL.Query()
+		should.So(t, query.Get("generation"), should.Equal, "1700000000000000")
+		assertSignatureV4(t, credentials, query.Get("X-Goog-Signature"), "20190404T204709Z", ""+
+			method+"\n"+
+			"/bucket/file.txt\n"+
+			"X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Credential=sample-key%40project-id-here.iam.gserviceaccount.com%2F20190404%2Fauto%2Fstorage%2Fgoog4_request&X-Goog-Date=20190404T204709Z&X-Goog-Expires=60&X-Goog-SignedHeaders=host&generation=1700000000000000\n"+
+			"host:storage.googleapis.com\n"+
+			"\n"+
+			"host\n"+
+			"UNSIGNED-PAYLOAD")
+	}
+}
+
+func TestDELETE(t *testing.T) {
+	request, err := NewRequest(DELETE, WithBucket("bucket"), WithResource("file.txt"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.Method, should.Equal, "DELETE")
+	should.So(t, request.URL.Path, should.Equal, "/bucket/file.txt")
+	should.So(t, request.Body, should.BeNil)
+}
+
+func TestDELETE_Preconditions(t *testing.T) {
+	request, _ := NewRequest(DELETE, WithBucket("bucket"), WithResource("file.txt"),
+		DeleteWithGeneration("42"), DeleteWithMetageneration("7"))
+
+	should.So(t, request.Header.Get("x-goog-if-generation-match"), should.Equal, "42")
+	should.So(t, request.Header.Get("x-goog-if-metageneration-match"), should.Equal, "7")
+}
+
+func TestDELETE_WithCredentials(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	frozen := time.Unix(1554410829, 0)
+
+	request, _ := NewRequest(DELETE, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(frozen),
+		DeleteWithGeneration("42"), DeleteWithMetageneration("7"))
+
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), ""+
+		"DELETE\n"+
+		"\n"+
+		"\n"+
+		"1554410829\n"+
+		"x-goog-if-generation-match:42\n"+
+		"x-goog-if-metageneration-match:7\n"+
+		"/bucket/file.txt")
+}
+
+func assertSignatureV2(t *testing.T, credentials Credentials, signature, stringToSign string) {
+	t.Helper()
+	digest := sha256.Sum256([]byte(stringToSign))
+	decoded, _ := base64.StdEncoding.DecodeString(signature)
+
+	err := rsa.VerifyPKCS1v15(&credentials.PrivateKey.inner.PublicKey, crypto.SHA256, digest[:], decoded)
+
+	should.So(t, err, should.BeNil)
+}
+
+func TestLIST(t *testing.T) {
+	request, err := NewRequest(LIST, WithBucket("bucket"),
+		ListWithPrefix("folder/"), ListWithDelimiter("/"), ListWithMaxResults(100),
+		ListWithStartOffset("folder/a"), ListWithPageToken("token"))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.Method, should.Equal, "GET")
+	should.So(t, request.URL.Path, should.Equal, "/bucket")
+	query := request.URL.Query()
+	should.So(t, query.Get("list-type"), should.Equal, "2")
+	should.So(t, query.Get("prefix"), should.Equal, "folder/")
+	should.So(t, query.Get("delimiter"), should.Equal, "/")
+	should.So(t, query.Get("max-keys"), should.Equal, "100")
+	should.So(t, query.Get("start-after"), should.Equal, "folder/a")
+	should.So(t, query.Get("continuation-token"), should.Equal, "token")
+}
+
+func TestLIST_WithCredentials(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	frozen := time.Unix(1554410829, 0)
+
+	request, _ := NewRequest(LIST, WithBucket("bucket"), ListWithPrefix("folder/"),
+		WithCredentials(credentials), WithSignedExpiration(frozen))
+
+	should.So(t, request.URL.Query().Get("prefix"), should.Equal, "folder/")
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), "GET\n\n\n1554410829\n/bucket")
+}
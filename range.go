@@ -0,0 +1,60 @@
+package gcs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ContentRange describes the portion of an object carried by a 206 (Partial Content) response, as reported by the
+// Content-Range header, e.g. "bytes 0-99/1000". Both First and Last are inclusive; Size is -1 when the complete length
+// of the object is not reported ("bytes 0-99/*").
+type ContentRange struct {
+	First int64
+	Last  int64
+	Size  int64
+}
+
+func (this ContentRange) Length() int64 { return this.Last - this.First + 1 }
+
+// ParseContentRange reads the value of a Content-Range header.
+// https://www.rfc-editor.org/rfc/rfc9110#field.content-range
+func ParseContentRange(value string) (ContentRange, error) {
+	unit, spec, _ := strings.Cut(strings.TrimSpace(value), " ")
+	span, size, found := strings.Cut(spec, "/")
+	first, last, ranged := strings.Cut(span, "-")
+	if unit != "bytes" || !found || !ranged {
+		return ContentRange{}, fmt.Errorf("%w [%s]", ErrMalformedContentRange, value)
+	}
+
+	result := ContentRange{Size: -1}
+	var err error
+	if result.First, err = strconv.ParseInt(first, 10, 64); err != nil || result.First < 0 {
+		return ContentRange{}, fmt.Errorf("%w [%s]", ErrMalformedContentRange, value)
+	} else if result.Last, err = strconv.ParseInt(last, 10, 64); err != nil || result.Last < result.First {
+		return ContentRange{}, fmt.Errorf("%w [%s]", ErrMalformedContentRange, value)
+	} else if size == "*" {
+		return result, nil
+	} else if result.Size, err = strconv.ParseInt(size, 10, 64); err != nil || result.Size <= result.Last {
+		return ContentRange{}, fmt.Errorf("%w [%s]", ErrMalformedContentRange, value)
+	}
+
+	return result, nil
+}
+
+// formatRange renders the Range header of GetWithRange: "bytes=100-199" for a length of 100 bytes at offset 100,
+// "bytes=100-" from the offset through the end of the object, and "bytes=-100" for the last 100 bytes (suffix).
+func formatRange(offset, length int64) string {
+	if offset < 0 {
+		return "bytes=" + strconv.FormatInt(offset, 10)
+	} else if length <= 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	} else {
+		return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+}
+
+const headerRange = "Range"
+
+var ErrMalformedContentRange = errors.New("malformed content range")
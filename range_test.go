@@ -0,0 +1,59 @@
+package gcs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestGetWithRange(t *testing.T) {
+	assertRangeHeader(t, GetWithRange(100, 100), "bytes=100-199")
+	assertRangeHeader(t, GetWithRange(0, 1), "bytes=0-0")
+	assertRangeHeader(t, GetWithRange(100, 0), "bytes=100-")
+	assertRangeHeader(t, GetWithRange(-100, 0), "bytes=-100")
+	assertRangeHeader(t, GetWithRange(-100, 50), "bytes=-100")
+	assertRangeHeader(t, nil, "")
+}
+func assertRangeHeader(t *testing.T, option Option, expected string) {
+	t.Helper()
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), option)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.Header.Get("Range"), should.Equal, expected)
+}
+
+func TestGetWithRange_IgnoredByOtherMethods(t *testing.T) {
+	for _, method := range []string{HEAD, PUT, DELETE} {
+		request, _ := NewRequest(method, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"),
+			GetWithRange(0, 10))
+
+		should.So(t, request.Header.Get("Range"), should.Equal, "")
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	assertContentRange(t, "bytes 0-99/1000", ContentRange{First: 0, Last: 99, Size: 1000})
+	assertContentRange(t, "bytes 900-999/1000", ContentRange{First: 900, Last: 999, Size: 1000})
+	assertContentRange(t, " bytes 5-5/* ", ContentRange{First: 5, Last: 5, Size: -1})
+}
+func assertContentRange(t *testing.T, value string, expected ContentRange) {
+	t.Helper()
+	parsed, err := ParseContentRange(value)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, parsed, should.Equal, expected)
+}
+
+func TestParseContentRange_Malformed(t *testing.T) {
+	for _, value := range []string{"", "bytes", "bytes */1000", "items 0-99/1000", "bytes 0-99", "bytes 99-0/1000",
+		"bytes 0-1000/1000", "bytes -1-99/1000", "bytes a-99/1000", "bytes 0-99/size"} {
+		_, err := ParseContentRange(value)
+
+		should.So(t, errors.Is(err, ErrMalformedContentRange), should.BeTrue)
+	}
+}
+
+func TestContentRangeLength(t *testing.T) {
+	should.So(t, ContentRange{First: 100, Last: 199}.Length(), should.Equal, int64(100))
+}
@@ -0,0 +1,274 @@
+package gcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ResumableUpload sends the content of a PUT request in chunks through a resumable upload session. Should any
+// chunk fail, calling Upload again queries the service for the number of bytes persisted and continues from there.
+// https://cloud.google.com/storage/docs/performing-resumable-uploads
+type ResumableUpload struct {
+	client httpClient
+	input  model
+
+	session     string
+	offset      int64  // number of bytes persisted by the service
+	buffer      []byte // bytes read from the content, but not yet acknowledged
+	bufferStart int64  // offset of the first byte within the buffer
+	final       bool   // buffer holds the end of the content
+	failed      bool   // the previous attempt did not complete, so the persisted offset must be queried
+	complete    bool
+	result      Object
+}
+
+// NewResumableUpload accepts the same options as a PUT request (e.g. WithCredentials, WithBucket, WithResource,
+// PutWithContent, PutWithContentType) as well as ResumableWithChunkSize and ResumableWithSession.
+func NewResumableUpload(client httpClient, options ...Option) (*ResumableUpload, error) {
+	input := newModel(PUT, options)
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = defaultHTTPClient()
+	}
+	if input.chunkSize <= 0 {
+		input.chunkSize = defaultChunkSize
+	} else if remainder := input.chunkSize % minimumChunkSize; remainder > 0 {
+		input.chunkSize += minimumChunkSize - remainder
+	}
+
+	return &ResumableUpload{
+		client:  client,
+		input:   input,
+		session: input.sessionURL,
+		failed:  len(input.sessionURL) > 0, // resuming a session from elsewhere; the offset is unknown
+	}, nil
+}
+
+func (this *ResumableUpload) SessionURL() string { return this.session }
+func (this *ResumableUpload) Offset() int64      { return this.offset }
+
+// Start initiates the upload session (if not already started) and returns its URL, which may be persisted and
+// later provided to ResumableWithSession. The session URL itself authorizes subsequent requests.
+func (this *ResumableUpload) Start() (string, error) {
+	if len(this.session) > 0 {
+		return this.session, nil
+	}
+
+	start := this.input
+	start.method = "POST"
+	start.resumable = true
+	start.content = nil
+	start.contentLength = 0
+	start.contentMD5 = "" // applies to the object content, not to the (empty) request initiating the session
+
+	request, err := start.buildRequest()
+	if err != nil {
+		return "", err
+	}
+
+	response, err := this.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+
+	defer drain(response)
+	if err = ParseErrorResponse(response); err != nil {
+		return "", err
+	} else if this.session = response.Header.Get("Location"); len(this.session) == 0 {
+		return "", ErrUploadSessionMissing
+	}
+
+	return this.session, nil
+}
+
+// Status queries the service for the number of bytes persisted thus far.
+func (this *ResumableUpload) Status() (int64, error) {
+	if _, err := this.Start(); err != nil {
+		return 0, err
+	}
+
+	response, err := this.send(nil, "*/"+this.totalLength())
+	if err != nil {
+		return 0, err
+	}
+
+	defer drain(response)
+	if err = this.acknowledge(response); err != nil {
+		return 0, err
+	}
+	return this.offset, nil
+}
+
+// Upload sends all remaining content, returning the attributes of the resulting object.
+func (this *ResumableUpload) Upload() (Object, error) {
+	if _, err := this.Start(); err != nil {
+		return Object{}, err
+	}
+
+	if this.failed {
+		if err := this.resume(); err != nil {
+			return Object{}, err
+		}
+	}
+
+	for !this.complete {
+		if err := this.uploadChunk(); err != nil {
+			this.failed = true
+			return Object{}, err
+		}
+	}
+
+	return this.result, nil
+}
+func (this *ResumableUpload) resume() error {
+	if _, err := this.Status(); err != nil {
+		return err
+	} else if this.complete {
+		return nil
+	} else if err = this.realign(); err != nil {
+		return err
+	}
+
+	this.failed = false
+	return nil
+}
+
+// realign discards buffered bytes already persisted by the service or, when the buffer doesn't cover the persisted
+// offset, rewinds the content to that offset (provided it is an io.Seeker).
+func (this *ResumableUpload) realign() error {
+	if skipped := this.offset - this.bufferStart; skipped >= 0 && skipped <= int64(len(this.buffer)) {
+		this.buffer = this.buffer[skipped:]
+	} else if seeker, ok := this.input.content.(io.Seeker); !ok {
+		return ErrUploadNotResumable
+	} else if _, err := seeker.Seek(this.offset, io.SeekStart); err != nil {
+		return err
+	} else {
+		this.buffer, this.final = nil, false
+	}
+
+	this.bufferStart = this.offset
+	return nil
+}
+func (this *ResumableUpload) uploadChunk() error {
+	if len(this.buffer) == 0 && !this.final {
+		if err := this.fill(); err != nil {
+			return err
+		}
+	}
+
+	contentRange := "*/" + this.totalLength()
+	if len(this.buffer) > 0 {
+		contentRange = fmt.Sprintf("%d-%d/%s", this.bufferStart, this.bufferStart+int64(len(this.buffer))-1, this.totalLength())
+	}
+
+	response, err := this.send(this.buffer, contentRange)
+	if err != nil {
+		return err
+	}
+
+	defer drain(response)
+	if err = this.acknowledge(response); err != nil {
+		return err
+	} else if this.complete {
+		return nil
+	}
+
+	return this.realign()
+}
+func (this *ResumableUpload) fill() error {
+	buffer := make([]byte, this.input.chunkSize)
+	read, err := io.ReadFull(this.input.content, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		this.final = true
+	} else if err != nil {
+		return err
+	}
+
+	this.buffer = buffer[:read]
+	this.bufferStart = this.offset
+	if this.input.contentLength > 0 && this.bufferStart+int64(read) >= this.input.contentLength {
+		this.final = true
+	}
+	return nil
+}
+func (this *ResumableUpload) totalLength() string {
+	if this.final {
+		return strconv.FormatInt(this.bufferStart+int64(len(this.buffer)), 10)
+	} else if this.input.contentLength > 0 {
+		return strconv.FormatInt(this.input.contentLength, 10)
+	} else {
+		return "*"
+	}
+}
+func (this *ResumableUpload) send(content []byte, contentRange string) (*http.Response, error) {
+	request, err := http.NewRequest(PUT, this.session, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	request.ContentLength = int64(len(content))
+	request.Header.Set(headerContentRange, "bytes "+contentRange)
+	return this.client.Do(request.WithContext(this.input.context))
+}
+
+// acknowledge interprets the response to a chunk or status request: 308 reports the persisted byte range while
+// 200/201 signal that the object has been created.
+func (this *ResumableUpload) acknowledge(response *http.Response) error {
+	if response.StatusCode == http.StatusPermanentRedirect {
+		this.offset = parsePersistedRange(response.Header.Get(headerRange))
+		return nil
+	} else if err := ParseErrorResponse(response); err != nil {
+		return err
+	}
+
+	result, err := newObject(response)
+	if err != nil {
+		return err
+	}
+
+	this.result = result
+	if len(response.Header.Get(headerStoredContentLength)) > 0 {
+		this.offset = this.result.Size
+	} else if this.final {
+		this.offset = this.bufferStart + int64(len(this.buffer))
+	} else if this.input.contentLength > 0 {
+		this.offset = this.input.contentLength
+	}
+
+	this.result.Size = this.offset
+	this.complete, this.buffer = true, nil
+	return nil
+}
+
+// parsePersistedRange returns the number of bytes persisted, given a header value such as "bytes=0-524287".
+func parsePersistedRange(value string) int64 {
+	index := strings.LastIndex(value, "-")
+	if index < 0 {
+		return 0 // nothing persisted yet
+	}
+
+	last, err := strconv.ParseInt(value[index+1:], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return last + 1
+}
+
+const (
+	headerContentRange = "Content-Range"
+	minimumChunkSize   = 256 * 1024
+	defaultChunkSize   = 32 * minimumChunkSize
+)
+
+var (
+	ErrUploadSessionMissing = errors.New("no resumable upload session was returned")
+	ErrUploadNotResumable   = errors.New("upload content cannot be rewound to the persisted offset")
+)
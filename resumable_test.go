@@ -0,0 +1,190 @@
+package gcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestResumableUpload_StartSignsSessionRequest(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	service := &FakeUploadService{}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(time.Unix(1554410829, 0)),
+		PutWithContentString("content"), PutWithContentType("text/plain"), PutWithGeneration("0"))
+
+	session, err := upload.Start()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, session, should.Equal, "https://storage.googleapis.com/bucket/file.txt?upload_id=session")
+	request := service.requests[0]
+	should.So(t, request.Method, should.Equal, "POST")
+	should.So(t, request.ContentLength, should.Equal, int64(0))
+	should.So(t, request.Header.Get("x-goog-resumable"), should.Equal, "start")
+	should.So(t, request.Header.Get("Content-Type"), should.Equal, "text/plain")
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), ""+
+		"POST\n"+
+		"\n"+
+		"text/plain\n"+
+		"1554410829\n"+
+		"x-goog-if-generation-match:0\n"+
+		"x-goog-resumable:start\n"+
+		"/bucket/file.txt")
+}
+func TestResumableUpload_Chunks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), minimumChunkSize/16*2+1)
+	service := &FakeUploadService{}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContent(bytes.NewBuffer(content)), ResumableWithChunkSize(1))
+
+	object, err := upload.Upload()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.content.Bytes(), should.Equal, content)
+	should.So(t, service.ranges, should.Equal, []string{
+		"bytes 0-262143/*",
+		"bytes 262144-524287/*",
+		"bytes 524288-524303/524304",
+	})
+	should.So(t, object.Size, should.Equal, int64(len(content)))
+	should.So(t, object.Generation, should.Equal, int64(42))
+}
+func TestResumableUpload_KnownLengthFinishesWithLastChunk(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), minimumChunkSize*2)
+	service := &FakeUploadService{}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContentBytes(content), ResumableWithChunkSize(minimumChunkSize))
+
+	_, err := upload.Upload()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.ranges, should.Equal, []string{"bytes 0-262143/524288", "bytes 262144-524287/524288"})
+}
+func TestResumableUpload_UnknownLengthEndingOnChunkBoundary(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), minimumChunkSize)
+	service := &FakeUploadService{}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContent(bytes.NewBuffer(content)), ResumableWithChunkSize(minimumChunkSize))
+
+	_, err := upload.Upload()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.ranges, should.Equal, []string{"bytes 0-262143/*", "bytes */262144"})
+	should.So(t, service.content.Len(), should.Equal, minimumChunkSize)
+}
+func TestResumableUpload_ResumeAfterFailure(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), minimumChunkSize/16*3)
+	service := &FakeUploadService{failOnChunk: 2, persistOnFailure: 1024}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContent(bytes.NewBuffer(content)), ResumableWithChunkSize(minimumChunkSize))
+
+	_, err := upload.Upload()
+	should.So(t, errors.Is(err, errFakeTransport), should.BeTrue)
+	should.So(t, upload.Offset(), should.Equal, int64(minimumChunkSize))
+
+	object, err := upload.Upload()
+	should.So(t, err, should.BeNil)
+	should.So(t, upload.Offset(), should.Equal, int64(len(content)))
+	should.So(t, object.Size, should.Equal, int64(len(content)))
+	should.So(t, service.content.Bytes(), should.Equal, content)
+	should.So(t, service.ranges[2], should.Equal, "bytes */*")
+	should.So(t, service.ranges[3], should.Equal, "bytes 263168-524287/*")
+}
+func TestResumableUpload_ResumeExistingSession(t *testing.T) {
+	content := []byte("0123456789")
+	service := &FakeUploadService{}
+	service.content.Write(content[:4])
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContentBytes(content), ResumableWithSession("https://storage.googleapis.com/bucket/file.txt?upload_id=existing"))
+
+	_, err := upload.Upload()
+
+	should.So(t, err, should.BeNil)
+	should.So(t, len(service.requests), should.Equal, 2) // status query and remaining content; no POST
+	should.So(t, service.requests[0].URL.Query().Get("upload_id"), should.Equal, "existing")
+	should.So(t, service.ranges, should.Equal, []string{"bytes */10", "bytes 4-9/10"})
+	should.So(t, service.content.Bytes(), should.Equal, content)
+}
+func TestResumableUpload_UnseekableContentCannotRewind(t *testing.T) {
+	service := &FakeUploadService{}
+	service.content.Write([]byte("0123"))
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContent(io.MultiReader(strings.NewReader("0123456789"))), ResumableWithSession("https://session"))
+
+	_, err := upload.Upload()
+
+	should.So(t, err, should.Equal, ErrUploadNotResumable)
+}
+func TestResumableUpload_StartFailure(t *testing.T) {
+	service := &FakeUploadService{startStatus: http.StatusForbidden}
+	upload, _ := NewResumableUpload(service, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"))
+
+	_, err := upload.Upload()
+
+	should.So(t, errors.Is(err, ErrAccessDenied), should.BeTrue)
+	should.So(t, upload.SessionURL(), should.Equal, "")
+}
+func TestResumableUpload_MissingContent(t *testing.T) {
+	upload, err := NewResumableUpload(&FakeUploadService{}, WithBucket("bucket"), WithResource("file.txt"))
+
+	should.So(t, err, should.Equal, ErrContentMissing)
+	should.So(t, upload, should.BeNil)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+// FakeUploadService mimics the resumable upload protocol of the XML API.
+type FakeUploadService struct {
+	requests         []*http.Request
+	ranges           []string
+	content          bytes.Buffer
+	chunks           int
+	startStatus      int
+	failOnChunk      int // 1-based; transport fails after persisting persistOnFailure bytes of the chunk
+	persistOnFailure int
+}
+
+func (this *FakeUploadService) Do(request *http.Request) (*http.Response, error) {
+	this.requests = append(this.requests, request)
+	if request.Method == "POST" {
+		if this.startStatus > 0 {
+			return newFakeResponse(this.startStatus, "", nil), nil
+		}
+		location := "https://storage.googleapis.com/bucket/file.txt?upload_id=session"
+		return newFakeResponse(http.StatusOK, "", http.Header{"Location": {location}}), nil
+	}
+
+	contentRange := request.Header.Get("Content-Range")
+	this.ranges = append(this.ranges, contentRange)
+	body, _ := io.ReadAll(request.Body)
+
+	if len(body) > 0 {
+		if this.chunks++; this.chunks == this.failOnChunk {
+			this.content.Write(body[:this.persistOnFailure])
+			return nil, errFakeTransport
+		}
+		start, _ := strconv.Atoi(contentRange[len("bytes "):strings.Index(contentRange, "-")])
+		this.content.Truncate(start)
+		this.content.Write(body)
+	}
+
+	if total := contentRange[strings.Index(contentRange, "/")+1:]; total == strconv.Itoa(this.content.Len()) {
+		return newFakeResponse(http.StatusOK, "", http.Header{"X-Goog-Generation": {"42"}}), nil
+	}
+
+	headers := http.Header{}
+	if this.content.Len() > 0 {
+		headers.Set("Range", fmt.Sprintf("bytes=0-%d", this.content.Len()-1))
+	}
+	return newFakeResponse(http.StatusPermanentRedirect, "", headers), nil
+}
+
+var errFakeTransport = errors.New("connection reset")
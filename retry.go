@@ -0,0 +1,205 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryClient decorates an HTTP client (e.g. the one provided to NewClient, WithResolverClient, or
+// CredentialOptions.HTTPClient) and retries idempotent requests which fail with a transient error (network failure,
+// 408, 429, or 5xx) using exponential backoff with full jitter. A Retry-After header takes precedence over the
+// calculated backoff, although one which exceeds the maximum backoff ends the retries, and no attempt is made which
+// cannot begin before the request context's deadline.
+type RetryClient struct {
+	inner          httpClient
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	idempotent     func(*http.Request) bool
+}
+
+// NewRetryClient retries only when used explicitly: neither NewClient nor the credential and token resolvers retry by
+// default. Token endpoint requests (STS, IAM Credentials, OAuth) are POSTs and so are not retried unless
+// RetryOptions.Idempotent says otherwise. Requests are signed once, before the first attempt, so a signed URL (which
+// expires 30 seconds after signing unless WithSignedExpiration says otherwise) may expire while attempts remain, after
+// which the service responds with 400 or 403 and no further attempts are made; allow for the maximum backoff when
+// choosing the expiration, or use bearer credentials.
+func NewRetryClient(inner httpClient, options ...retryOption) *RetryClient {
+	var config retryConfig
+	RetryOptions.apply(options...)(&config)
+
+	if inner == nil {
+		inner = defaultHTTPClient()
+	}
+
+	return &RetryClient{
+		inner:          inner,
+		maxAttempts:    config.maxAttempts,
+		initialBackoff: config.initialBackoff,
+		maxBackoff:     config.maxBackoff,
+		idempotent:     config.idempotent,
+	}
+}
+
+func (this *RetryClient) Do(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	current := request
+
+	for attempt := 1; ; attempt++ {
+		response, err := this.inner.Do(current)
+		if attempt >= this.maxAttempts || !this.retryable(request, response, err) {
+			return response, err
+		}
+
+		delay, ok := this.backoff(attempt, response)
+		if !ok {
+			return response, err // the service asked for a longer pause than the maximum backoff
+		} else if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return response, err // no time remains for another attempt
+		}
+
+		if response != nil {
+			drain(response)
+		}
+
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		if current, err = rewind(request); err != nil {
+			return nil, err
+		}
+	}
+}
+func (this *RetryClient) retryable(request *http.Request, response *http.Response, err error) bool {
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		return false // the body cannot be sent a second time
+	} else if !this.idempotent(request) {
+		return false
+	} else if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	} else {
+		return isTransientStatus(response.StatusCode)
+	}
+}
+func isTransientStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= http.StatusInternalServerError
+}
+func (this *RetryClient) backoff(attempt int, response *http.Response) (time.Duration, bool) {
+	if response != nil {
+		if delay, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			return delay, delay <= this.maxBackoff
+		}
+	}
+
+	return fullJitter(attempt, this.initialBackoff, this.maxBackoff), true
+}
+func fullJitter(attempt int, initial, maximum time.Duration) time.Duration {
+	ceiling := initial
+	for i := 1; i < attempt && ceiling < maximum; i++ {
+		ceiling *= 2
+	}
+
+	return time.Duration(rand.Int64N(int64(min(ceiling, maximum)) + 1)) // "full jitter"
+}
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	} else if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	} else if instant, err := http.ParseTime(value); err == nil {
+		return max(time.Until(instant), 0), true
+	} else {
+		return 0, false
+	}
+}
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+func rewind(request *http.Request) (*http.Request, error) {
+	clone := request.Clone(request.Context())
+	if request.GetBody == nil {
+		return clone, nil
+	}
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	clone.Body = body
+	return clone, nil
+}
+
+// isIdempotent reports whether repeating the request is safe: reads, writes guarded by a generation precondition, or
+// deletion of a specific (immutable) generation of an object (see GetWithGeneration).
+func isIdempotent(request *http.Request) bool {
+	switch request.Method {
+	case GET, HEAD:
+		return true
+	case PUT:
+		return len(request.Header.Get(headerGeneration)) > 0
+	case DELETE:
+		return len(request.Header.Get(headerGeneration)) > 0 || len(request.URL.Query().Get(queryGeneration)) > 0
+	default:
+		return false
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type retryConfig struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	idempotent     func(*http.Request) bool
+}
+type retrySingleton struct{}
+type retryOption func(*retryConfig)
+
+var RetryOptions retrySingleton
+
+// MaxAttempts includes the initial attempt; a value of 1 disables retries.
+func (retrySingleton) MaxAttempts(value int) retryOption {
+	return func(this *retryConfig) { this.maxAttempts = max(value, 1) }
+}
+
+// Backoff sets the bounds of the exponential backoff; the maximum also bounds any pause requested using Retry-After.
+func (retrySingleton) Backoff(initial, maximum time.Duration) retryOption {
+	return func(this *retryConfig) { this.initialBackoff = initial; this.maxBackoff = max(initial, maximum) }
+}
+
+// Idempotent replaces the rule which decides whether a request may be repeated. Requests to token and credential
+// endpoints (see WithResolverClient and CredentialOptions.HTTPClient) are safe to repeat even though they are POSTs:
+// RetryOptions.Idempotent(func(*http.Request) bool { return true }).
+func (retrySingleton) Idempotent(value func(*http.Request) bool) retryOption {
+	return func(this *retryConfig) { this.idempotent = value }
+}
+func (retrySingleton) apply(options ...retryOption) retryOption {
+	return func(this *retryConfig) {
+		for _, option := range RetryOptions.defaults(options...) {
+			option(this)
+		}
+	}
+}
+func (retrySingleton) defaults(options ...retryOption) []retryOption {
+	return append([]retryOption{
+		RetryOptions.MaxAttempts(5),
+		RetryOptions.Backoff(time.Millisecond*250, time.Second*32),
+		RetryOptions.Idempotent(isIdempotent),
+	}, options...)
+}
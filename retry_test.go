@@ -0,0 +1,185 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestRetry_TransientStatusThenSuccess(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusServiceUnavailable, "", nil),
+		newFakeResponse(http.StatusTooManyRequests, "", nil),
+		newFakeResponse(http.StatusOK, "content", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"))
+
+	response, err := client.Do(request)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, response.StatusCode, should.Equal, http.StatusOK)
+	should.So(t, len(fake.requests), should.Equal, 3)
+}
+func TestRetry_AttemptsExhausted(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusInternalServerError, "", nil)}}
+	client := NewRetryClient(fake, RetryOptions.MaxAttempts(2), RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(HEAD, WithBucket("bucket"), WithResource("file.txt"))
+
+	response, err := client.Do(request)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, response.StatusCode, should.Equal, http.StatusInternalServerError)
+	should.So(t, len(fake.requests), should.Equal, 2)
+}
+func TestRetry_NetworkFailure(t *testing.T) {
+	transportErr := errors.New("connection reset")
+	fake := &FakeHTTPClient{err: transportErr}
+	client := NewRetryClient(fake, RetryOptions.MaxAttempts(3), RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"))
+
+	response, err := client.Do(request)
+
+	should.So(t, err, should.Equal, transportErr)
+	should.So(t, response, should.BeNil)
+	should.So(t, len(fake.requests), should.Equal, 3)
+}
+func TestRetry_PermanentStatusNotRetried(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusNotFound, "", nil)}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"))
+
+	response, _ := client.Do(request)
+
+	should.So(t, response.StatusCode, should.Equal, http.StatusNotFound)
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_UnconditionalPutNotRetried(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusServiceUnavailable, "", nil)}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"), PutWithContentString("hi"))
+
+	_, _ = client.Do(request)
+
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_ConditionalPutResendsBody(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusServiceUnavailable, "", nil),
+		newFakeResponse(http.StatusOK, "", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContentString("hi"), PutWithGeneration("0"))
+
+	_, err := client.Do(request)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, len(fake.requests), should.Equal, 2)
+	body, _ := io.ReadAll(fake.requests[1].Body)
+	should.So(t, string(body), should.Equal, "hi")
+}
+func TestRetry_UnrewindableBodyNotRetried(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusServiceUnavailable, "", nil)}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(PUT, WithBucket("bucket"), WithResource("file.txt"),
+		PutWithContent(io.MultiReader(strings.NewReader("hi"))), PutWithGeneration("0"))
+
+	_, _ = client.Do(request)
+
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_UnconditionalDeleteNotRetried(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusServiceUnavailable, "", nil)}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(DELETE, WithBucket("bucket"), WithResource("file.txt"))
+
+	_, _ = client.Do(request)
+
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_DeleteOfGenerationRetried(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusServiceUnavailable, "", nil),
+		newFakeResponse(http.StatusNoContent, "", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(DELETE, WithBucket("bucket"), WithResource("file.txt"), GetWithGeneration("1700000000000000"))
+
+	response, err := client.Do(request)
+
+	should.So(t, err, should.BeNil)
+	should.So(t, response.StatusCode, should.Equal, http.StatusNoContent)
+	should.So(t, len(fake.requests), should.Equal, 2)
+	should.So(t, fake.requests[1].URL.Query().Get("generation"), should.Equal, "1700000000000000")
+}
+func TestRetry_CustomIdempotentRule(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusBadGateway, "", nil),
+		newFakeResponse(http.StatusOK, "", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond),
+		RetryOptions.Idempotent(func(*http.Request) bool { return true }))
+	request, _ := http.NewRequest("POST", tokenURL, strings.NewReader("{}"))
+
+	response, _ := client.Do(request)
+
+	should.So(t, response.StatusCode, should.Equal, http.StatusOK)
+	should.So(t, len(fake.requests), should.Equal, 2)
+}
+func TestRetry_RetryAfterBeyondDeadline(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusTooManyRequests, "", http.Header{"Retry-After": {"10"}}),
+		newFakeResponse(http.StatusOK, "", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Minute))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithContext(ctx))
+
+	response, _ := client.Do(request)
+
+	should.So(t, response.StatusCode, should.Equal, http.StatusTooManyRequests)
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_RetryAfterBeyondMaxBackoff(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusServiceUnavailable, "", http.Header{"Retry-After": {"86400"}}),
+		newFakeResponse(http.StatusOK, "", nil),
+	}}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Second))
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"))
+
+	response, _ := client.Do(request)
+
+	should.So(t, response.StatusCode, should.Equal, http.StatusServiceUnavailable)
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestRetry_CanceledContextNotRetried(t *testing.T) {
+	fake := &FakeHTTPClient{err: context.Canceled}
+	client := NewRetryClient(fake, RetryOptions.Backoff(time.Millisecond, time.Millisecond))
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"))
+
+	_, err := client.Do(request)
+
+	should.So(t, err, should.Equal, context.Canceled)
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("3")
+	should.So(t, delay, should.Equal, time.Second*3)
+	should.So(t, ok, should.BeTrue)
+
+	delay, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	should.So(t, delay, should.Equal, time.Duration(0))
+	should.So(t, ok, should.BeTrue)
+
+	_, ok = parseRetryAfter("soon")
+	should.So(t, ok, should.BeFalse)
+}
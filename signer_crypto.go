@@ -0,0 +1,45 @@
+package gcs
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"reflect"
+)
+
+// NewCryptoSigner adapts a crypto.Signer holding an RSA key (e.g. one backed by a PKCS #11 token, a cloud KMS, or an
+// agent) such that the private key never needs to be loaded into process memory.
+func NewCryptoSigner(inner crypto.Signer) (Signer, error) {
+	if inner == nil || isNilPointer(inner) {
+		return nil, ErrMalformedPrivateKey // e.g. (*rsa.PrivateKey)(nil), whose Public method would panic
+	} else if _, ok := inner.Public().(*rsa.PublicKey); !ok {
+		return nil, ErrUnsupportedPrivateKey // Cloud Storage only verifies RSA-SHA256 signatures
+	} else {
+		return &cryptoSigner{inner: inner}, nil
+	}
+}
+
+// NewCredentialsWithSigner is the counterpart of NewCredentials for keys held outside of process memory.
+func NewCredentialsWithSigner(accessID string, inner crypto.Signer) (Credentials, error) {
+	if signer, err := NewCryptoSigner(inner); err != nil {
+		return Credentials{}, err
+	} else {
+		return Credentials{AccessID: accessID, Signer: signer}, nil
+	}
+}
+
+func isNilPointer(value any) bool {
+	reflected := reflect.ValueOf(value)
+	return reflected.Kind() == reflect.Pointer && reflected.IsNil()
+}
+
+type cryptoSigner struct {
+	inner crypto.Signer
+}
+
+func (this *cryptoSigner) Sign(_ context.Context, raw []byte) ([]byte, error) {
+	sum := sha256.Sum256(raw)
+	return this.inner.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
@@ -0,0 +1,44 @@
+package gcs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestCredentialsWithSigner(t *testing.T) {
+	key, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	credentials, err := NewCredentialsWithSigner("sample-key@project-id-here.iam.gserviceaccount.com", key.PrivateKey.inner)
+	should.So(t, err, should.BeNil)
+
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(time.Unix(1554410829, 0)))
+
+	should.So(t, request.URL.Query().Get("GoogleAccessId"), should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	assertSignatureV2(t, key, request.URL.Query().Get("Signature"), "GET\n\n\n1554410829\n/bucket/file.txt")
+}
+func TestCryptoSigner_RSARequired(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	signer, err := NewCryptoSigner(key)
+
+	should.So(t, signer, should.BeNil)
+	should.So(t, err, should.Equal, ErrUnsupportedPrivateKey)
+}
+func TestCryptoSigner_Missing(t *testing.T) {
+	credentials, err := NewCredentialsWithSigner("sample-key@project-id-here.iam.gserviceaccount.com", nil)
+
+	should.So(t, credentials, should.Equal, Credentials{})
+	should.So(t, err, should.Equal, ErrMalformedPrivateKey)
+}
+func TestCryptoSigner_TypedNil(t *testing.T) {
+	signer, err := NewCryptoSigner((*rsa.PrivateKey)(nil))
+
+	should.So(t, signer, should.BeNil)
+	should.So(t, err, should.Equal, ErrMalformedPrivateKey)
+}
@@ -0,0 +1,81 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// NewIAMSigner signs on behalf of the service account (email) provided using the IAM Credentials API such that signed
+// URLs may be produced without a local private key, e.g. by workloads holding only metadata server credentials. The
+// base credentials, which must produce a bearer token, require the roles/iam.serviceAccountTokenCreator role on the
+// service account. Calls to the API use the context of the request being signed (see WithContext) and the client and
+// delegates set using WithResolverClient and WithResolverDelegates. The signer is used as follows:
+// Credentials{AccessID: email, Signer: signer}
+// https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/signBlob
+func NewIAMSigner(base Credentials, email string, options ...ResolverOption) (Signer, error) {
+	if len(email) == 0 {
+		return nil, ErrServiceAccountRequired
+	} else if len(base.BearerToken) == 0 && base.TokenSource == nil {
+		return nil, ErrBearerCredentialsRequired
+	}
+
+	resolver := newTokenResolver(options...)
+	return &iamSigner{
+		client:    resolver.client,
+		base:      base,
+		url:       iamCredentialsURL + email + ":signBlob",
+		delegates: qualifyDelegates(resolver.delegates),
+	}, nil
+}
+
+type iamSigner struct {
+	client    httpClient
+	base      Credentials
+	url       string
+	delegates []string
+}
+
+func (this *iamSigner) Sign(ctx context.Context, raw []byte) ([]byte, error) {
+	bearerToken, err := this.base.bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _ := json.Marshal(struct {
+		Delegates []string `json:"delegates,omitempty"`
+		Payload   []byte   `json:"payload"` // base64-encoded by encoding/json
+	}{Delegates: this.delegates, Payload: raw})
+
+	request, err := http.NewRequest("POST", this.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Authorization", bearerToken)
+	request.Header.Set(headerContentType, "application/json")
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	defer drain(response)
+	if err = ParseErrorResponse(response); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFailedSignRequest, err)
+	}
+
+	var result struct {
+		SignedBlob string `json:"signedBlob"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to parse response body returned from the IAM Credentials API: %w", err)
+	}
+
+	return base64.StdEncoding.DecodeString(result.SignedBlob)
+}
+
+var ErrFailedSignRequest = errors.New("unable to sign using the IAM Credentials API")
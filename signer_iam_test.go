@@ -0,0 +1,130 @@
+package gcs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestIAMSigner_SignedURL(t *testing.T) {
+	key, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	service := &FakeSignBlobService{key: key.PrivateKey}
+	signer, err := NewIAMSigner(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com",
+		WithResolverClient(service), WithResolverDelegates("delegate@project.iam.gserviceaccount.com"))
+	should.So(t, err, should.BeNil)
+	credentials := Credentials{AccessID: "target@project.iam.gserviceaccount.com", Signer: signer}
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(credentials), WithSignedExpiration(time.Unix(1554410829, 0)))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.URL.Query().Get("GoogleAccessId"), should.Equal, "target@project.iam.gserviceaccount.com")
+	assertSignatureV2(t, key, request.URL.Query().Get("Signature"), "GET\n\n\n1554410829\n/bucket/file.txt")
+	should.So(t, service.request.URL.String(), should.Equal,
+		"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/target@project.iam.gserviceaccount.com:signBlob")
+	should.So(t, service.request.Header.Get("Authorization"), should.Equal, "Bearer base-token")
+	should.So(t, service.body.Delegates, should.Equal, []string{"projects/-/serviceAccounts/delegate@project.iam.gserviceaccount.com"})
+}
+func TestIAMSigner_SignedURLV4(t *testing.T) {
+	key, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	signer, _ := NewIAMSigner(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com",
+		WithResolverClient(&FakeSignBlobService{key: key.PrivateKey}))
+	credentials := Credentials{AccessID: "target@project.iam.gserviceaccount.com", Signer: signer}
+	signedAt := time.Unix(1554410829, 0).UTC()
+	input := newModel(GET, []Option{WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials),
+		WithSigningVersion(V4), WithSignedExpiration(signedAt.Add(time.Minute))})
+	input.signedAt = signedAt
+
+	request, err := input.buildRequest()
+
+	should.So(t, err, should.BeNil)
+	assertSignatureV4(t, key, request.URL.Query().Get("X-Goog-Signature"), "20190404T204709Z", ""+
+		"GET\n"+
+		"/bucket/file.txt\n"+
+		"X-Goog-Algorithm=GOOG4-RSA-SHA256&X-Goog-Credential=target%40project.iam.gserviceaccount.com%2F20190404%2Fauto%2Fstorage%2Fgoog4_request&X-Goog-Date=20190404T204709Z&X-Goog-Expires=60&X-Goog-SignedHeaders=host\n"+
+		"host:storage.googleapis.com\n"+
+		"\n"+
+		"host\n"+
+		"UNSIGNED-PAYLOAD")
+}
+func TestIAMSigner_Rejected(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusForbidden, `{"error": {"code": 403, "message": "Permission denied", "status": "PERMISSION_DENIED"}}`, nil)}}
+	signer, _ := NewIAMSigner(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com", WithResolverClient(fake))
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(Credentials{AccessID: "target@project.iam.gserviceaccount.com", Signer: signer}))
+
+	should.So(t, request, should.BeNil)
+	should.So(t, errors.Is(err, ErrFailedSignRequest), should.BeTrue)
+	should.So(t, errors.Is(err, ErrAccessDenied), should.BeTrue)
+}
+func TestIAMSigner_UsesRequestContext(t *testing.T) {
+	key, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	service := &FakeSignBlobService{key: key.PrivateKey}
+	signer, _ := NewIAMSigner(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com",
+		WithResolverClient(service))
+	type contextKey struct{}
+	ctx := context.WithValue(context.Background(), contextKey{}, "request")
+
+	_, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithContext(ctx),
+		WithCredentials(Credentials{AccessID: "target@project.iam.gserviceaccount.com", Signer: signer}))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, service.request.Context().Value(contextKey{}), should.Equal, "request")
+}
+func TestIAMSigner_CanceledRequest(t *testing.T) {
+	signer, _ := NewIAMSigner(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com",
+		WithResolverClient(defaultHTTPClient()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithContext(ctx),
+		WithCredentials(Credentials{AccessID: "target@project.iam.gserviceaccount.com", Signer: signer}))
+
+	should.So(t, request, should.BeNil)
+	should.So(t, errors.Is(err, context.Canceled), should.BeTrue)
+}
+func TestIAMSigner_BaseWithoutBearer(t *testing.T) {
+	signer, err := NewIAMSigner(Credentials{}, "target@project.iam.gserviceaccount.com")
+
+	should.So(t, signer, should.BeNil)
+	should.So(t, err, should.Equal, ErrBearerCredentialsRequired)
+}
+func TestCredentials_SignerPreferredToPrivateKey(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	credentials.Signer = FakeSigner("signature")
+
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+
+	should.So(t, request.URL.Query().Get("Signature"), should.Equal, base64.StdEncoding.EncodeToString([]byte("signature")))
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+type FakeSigner string
+
+func (this FakeSigner) Sign(context.Context, []byte) ([]byte, error) { return []byte(this), nil }
+
+// FakeSignBlobService signs payloads, as the IAM Credentials API would, using the key provided.
+type FakeSignBlobService struct {
+	key     PrivateKey
+	request *http.Request
+	body    struct {
+		Delegates []string `json:"delegates"`
+		Payload   []byte   `json:"payload"`
+	}
+}
+
+func (this *FakeSignBlobService) Do(request *http.Request) (*http.Response, error) {
+	this.request = request
+	_ = json.NewDecoder(request.Body).Decode(&this.body)
+	signed, _ := this.key.Sign(this.body.Payload)
+	raw, _ := json.Marshal(map[string]string{"keyId": "key-id", "signedBlob": base64.StdEncoding.EncodeToString(signed)})
+	return newFakeResponse(http.StatusOK, string(raw), nil), nil
+}
@@ -0,0 +1,128 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NewCachingTokenResolver caches the access token of each ClientIdentity until shortly before it expires. Concurrent
+// requests for the same identity share a single call to the inner resolver.
+func NewCachingTokenResolver(inner TokenResolver) TokenResolver {
+	return &cachingResolver{inner: inner, caches: make(map[ClientIdentity]*tokenCache)}
+}
+
+// NewCachingTokenSource caches the access token of the inner source until shortly before it expires. Concurrent
+// requests share a single call to the inner source.
+func NewCachingTokenSource(inner TokenSource) TokenSource {
+	return newTokenCache(inner.Token)
+}
+
+type cachingResolver struct {
+	inner  TokenResolver
+	mutex  sync.Mutex
+	caches map[ClientIdentity]*tokenCache
+}
+
+func (this *cachingResolver) AccessToken(identity ClientIdentity) (AccessToken, error) {
+	this.mutex.Lock()
+	cache, found := this.caches[identity]
+	if !found {
+		cache = newTokenCache(func(context.Context) (AccessToken, error) { return this.inner.AccessToken(identity) })
+		this.caches[identity] = cache
+	}
+	this.mutex.Unlock()
+
+	return cache.Token(context.Background())
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+type tokenCache struct {
+	refresh func(context.Context) (AccessToken, error)
+	now     func() time.Time
+
+	mutex   sync.Mutex
+	current AccessToken
+	expires time.Time
+	renew   time.Time // once passed, a new token is requested although the current one remains usable
+	pending *tokenCall
+}
+type tokenCall struct {
+	done  chan struct{}
+	token AccessToken
+	err   error
+}
+
+func newTokenCache(refresh func(context.Context) (AccessToken, error)) *tokenCache {
+	return &tokenCache{refresh: refresh, now: time.Now}
+}
+
+func (this *tokenCache) Token(ctx context.Context) (AccessToken, error) {
+	for {
+		this.mutex.Lock()
+		if this.now().Before(this.renew) {
+			defer this.mutex.Unlock()
+			return this.current, nil
+		}
+
+		call, leader := this.pending, false
+		if call == nil {
+			call, leader = &tokenCall{done: make(chan struct{})}, true
+			this.pending = call
+		}
+		this.mutex.Unlock()
+
+		if leader {
+			call.token, call.err = this.refresh(ctx)
+			this.store(call)
+			close(call.done)
+		} else {
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return AccessToken{}, ctx.Err()
+			}
+		}
+
+		if !leader && isContextError(call.err) && ctx.Err() == nil {
+			continue // the leader was canceled (or ran out of time) rather than the refresh failing, so try again
+		} else if call.err != nil {
+			return this.fallback(call.err)
+		}
+		return call.token, nil
+	}
+}
+func (this *tokenCache) store(call *tokenCall) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.pending = nil
+	if call.err != nil {
+		return
+	}
+
+	now := this.now()
+	lifetime := time.Duration(call.token.Expiration) * time.Second
+	this.current = call.token
+	this.expires = now.Add(lifetime)
+	this.renew = now.Add(lifetime - min(tokenRefreshWindow, lifetime/2))
+}
+
+// fallback continues to offer the current token after a failed (early) refresh, provided it hasn't yet expired.
+func (this *tokenCache) fallback(err error) (AccessToken, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.now().Before(this.expires) {
+		return this.current, nil
+	}
+	return AccessToken{}, err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+const tokenRefreshWindow = time.Minute * 5
@@ -0,0 +1,174 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestTokenCache_ReusesTokenUntilRefreshWindow(t *testing.T) {
+	source := &FakeTokenSource{tokens: []AccessToken{{Value: "1", Expiration: 3600}, {Value: "2", Expiration: 3600}}}
+	cache := NewCachingTokenSource(source).(*tokenCache)
+	clock := time.Now()
+	cache.now = func() time.Time { return clock }
+
+	first, _ := cache.Token(context.Background())
+	clock = clock.Add(time.Minute * 54)
+	second, _ := cache.Token(context.Background())
+	clock = clock.Add(time.Minute * 2) // within five minutes of expiration
+	third, _ := cache.Token(context.Background())
+
+	should.So(t, first.Value, should.Equal, "1")
+	should.So(t, second.Value, should.Equal, "1")
+	should.So(t, third.Value, should.Equal, "2")
+	should.So(t, source.calls.Load(), should.Equal, int32(2))
+}
+func TestTokenCache_FailedEarlyRefreshKeepsCurrentToken(t *testing.T) {
+	source := &FakeTokenSource{tokens: []AccessToken{{Value: "1", Expiration: 3600}}}
+	cache := NewCachingTokenSource(source).(*tokenCache)
+	clock := time.Now()
+	cache.now = func() time.Time { return clock }
+	_, _ = cache.Token(context.Background())
+
+	source.err = errors.New("unavailable")
+	clock = clock.Add(time.Minute * 58)
+	beforeExpiration, err1 := cache.Token(context.Background())
+	clock = clock.Add(time.Minute * 2)
+	afterExpiration, err2 := cache.Token(context.Background())
+
+	should.So(t, beforeExpiration.Value, should.Equal, "1")
+	should.So(t, err1, should.BeNil)
+	should.So(t, afterExpiration, should.Equal, AccessToken{})
+	should.So(t, err2, should.Equal, source.err)
+}
+func TestTokenCache_TokenWithoutExpirationIsNotCached(t *testing.T) {
+	source := &FakeTokenSource{tokens: []AccessToken{{Value: "1"}, {Value: "2"}}}
+	cache := NewCachingTokenSource(source)
+
+	first, _ := cache.Token(context.Background())
+	second, _ := cache.Token(context.Background())
+
+	should.So(t, first.Value, should.Equal, "1")
+	should.So(t, second.Value, should.Equal, "2")
+}
+func TestTokenCache_ConcurrentRequestsShareRefresh(t *testing.T) {
+	release := make(chan struct{})
+	source := &FakeTokenSource{tokens: []AccessToken{{Value: "1", Expiration: 3600}}, block: release}
+	cache := NewCachingTokenSource(source)
+
+	var waiter sync.WaitGroup
+	results := make([]AccessToken, 16)
+	for i := range results {
+		waiter.Add(1)
+		go func(index int) {
+			defer waiter.Done()
+			results[index], _ = cache.Token(context.Background())
+		}(i)
+	}
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	waiter.Wait()
+
+	should.So(t, source.calls.Load(), should.Equal, int32(1))
+	for _, result := range results {
+		should.So(t, result.Value, should.Equal, "1")
+	}
+}
+func TestTokenCache_CanceledLeaderDoesNotFailFollowers(t *testing.T) {
+	started := make(chan struct{})
+	var calls atomic.Int32
+	cache := newTokenCache(func(ctx context.Context) (AccessToken, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return AccessToken{}, ctx.Err()
+		}
+		return AccessToken{Value: "1", Expiration: 3600}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var leaderErr error
+	leaderDone := make(chan struct{})
+	go func() { defer close(leaderDone); _, leaderErr = cache.Token(ctx) }()
+	<-started
+
+	var follower AccessToken
+	var followerErr error
+	followerDone := make(chan struct{})
+	go func() { defer close(followerDone); follower, followerErr = cache.Token(context.Background()) }()
+	time.Sleep(time.Millisecond * 10)
+	cancel()
+	<-leaderDone
+	<-followerDone
+
+	should.So(t, leaderErr, should.Equal, context.Canceled)
+	should.So(t, followerErr, should.BeNil)
+	should.So(t, follower.Value, should.Equal, "1")
+	should.So(t, calls.Load(), should.Equal, int32(2))
+}
+func TestCachingTokenResolver_CachesPerIdentity(t *testing.T) {
+	inner := &FakeTokenResolver{}
+	resolver := NewCachingTokenResolver(inner)
+
+	first, _ := resolver.AccessToken(ClientIdentity{ID: "a"})
+	second, _ := resolver.AccessToken(ClientIdentity{ID: "a"})
+	third, _ := resolver.AccessToken(ClientIdentity{ID: "b"})
+
+	should.So(t, first.Value, should.Equal, "a")
+	should.So(t, second.Value, should.Equal, "a")
+	should.So(t, third.Value, should.Equal, "b")
+	should.So(t, inner.calls, should.Equal, 2)
+}
+func TestRefreshingCredentialsResolveTokenAtRequestTime(t *testing.T) {
+	credentials, err := ParseCredentialsFromJSON(sampleClientIdentityJSON, WithResolverClient(FakeClient{}), WithResolverRefresh(true))
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.BearerToken, should.Equal, "")
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.Header.Get("Authorization"), should.Equal, "ResolvedTokenType ResolvedAccessToken")
+	should.So(t, request.URL.Query().Get("Signature"), should.Equal, "")
+}
+func TestTokenSourceFailureFailsRequest(t *testing.T) {
+	source := &FakeTokenSource{err: errors.New("unavailable")}
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(Credentials{TokenSource: source}))
+
+	should.So(t, err, should.Equal, source.err)
+	should.So(t, request, should.BeNil)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+type FakeTokenSource struct {
+	tokens   []AccessToken
+	err      error
+	errAfter int // the number of calls which succeed before err is returned
+	block    chan struct{}
+	calls    atomic.Int32
+}
+
+func (this *FakeTokenSource) Token(_ context.Context) (AccessToken, error) {
+	if this.block != nil {
+		<-this.block
+	}
+	calls := int(this.calls.Add(1))
+	if this.err != nil && calls > this.errAfter {
+		return AccessToken{}, this.err
+	}
+	return this.tokens[min(calls, len(this.tokens))-1], nil
+}
+
+type FakeTokenResolver struct{ calls int }
+
+func (this *FakeTokenResolver) AccessToken(identity ClientIdentity) (AccessToken, error) {
+	this.calls++
+	return AccessToken{Value: identity.ID, Expiration: 3600}, nil
+}
@@ -0,0 +1,180 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NewImpersonatedCredentials uses the base credentials (e.g. those returned by a CredentialsReader) to act as the
+// target service account. Base credentials holding the private key of a service account (e.g. read from a key file)
+// obtain their bearer token using the JWT bearer grant, as NewServiceAccountTokenSource does; others must produce a
+// bearer token. Access tokens for the target are requested from the IAM
+// Credentials API as needed and cached until shortly before they expire. The scopes, lifetime, and delegation chain
+// may be set using WithResolverScopes, WithResolverLifetime, and WithResolverDelegates.
+func NewImpersonatedCredentials(base Credentials, target string, options ...ResolverOption) (Credentials, error) {
+	if len(target) == 0 {
+		return Credentials{}, ErrServiceAccountRequired
+	}
+
+	return newImpersonatedCredentials(base, fmt.Sprintf(impersonationURLFormat, target), newTokenResolver(options...))
+}
+func newImpersonatedCredentials(base Credentials, url string, resolver *defaultResolver) (Credentials, error) {
+	if len(base.BearerToken) == 0 && base.PrivateKey.inner != nil && len(base.AccessID) > 0 {
+		// in place of any self-signed JWT, which the IAM Credentials API won't accept (its audience is Cloud Storage)
+		base = Credentials{AccessID: base.AccessID, TokenSource: newKeyTokenSource(base, resolver)}
+	} else if len(base.BearerToken) == 0 && base.TokenSource == nil {
+		return Credentials{}, ErrBearerCredentialsRequired
+	}
+
+	return Credentials{
+		AccessID:   impersonatedEmail(url),
+		Provenance: Provenance{Scopes: strings.Join(resolver.scopes, " ")},
+		TokenSource: NewCachingTokenSource(&impersonatedTokenSource{
+			client:    resolver.client,
+			base:      base,
+			url:       url,
+			scopes:    resolver.scopes,
+			delegates: qualifyDelegates(resolver.delegates),
+			lifetime:  resolver.lifetime,
+			now:       time.Now,
+		}),
+	}, nil
+}
+
+// parseImpersonatedCredentials handles the "impersonated_service_account" JSON written by
+// `gcloud auth application-default login --impersonate-service-account`.
+func parseImpersonatedCredentials(raw []byte, options ...ResolverOption) (Credentials, error) {
+	var parsed struct {
+		URL       string          `json:"service_account_impersonation_url"`
+		Delegates []string        `json:"delegates"`
+		Source    json.RawMessage `json:"source_credentials"`
+	}
+	if err := json.Unmarshal(raw, &parsed); err != nil || len(parsed.URL) == 0 || len(parsed.Source) == 0 {
+		return Credentials{}, ErrMalformedJSON
+	}
+
+	base, err := parseSourceCredentials(parsed.Source, options...)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	resolver := newTokenResolver(options...)
+	if len(resolver.delegates) == 0 {
+		resolver.delegates = parsed.Delegates
+	}
+
+	return newImpersonatedCredentials(base, parsed.URL, resolver)
+}
+
+// parseSourceCredentials returns credentials which produce (refreshing) bearer tokens suitable for calling the IAM
+// Credentials API, regardless of the options provided for the impersonated credentials.
+func parseSourceCredentials(raw []byte, options ...ResolverOption) (Credentials, error) {
+	parsed, err := unmarshalClientCredentials(raw)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	options = append(options[:len(options):len(options)], WithResolverRefresh(true), WithResolverSelfSigned(false))
+	if parsed.Type != serviceAccountType {
+		return ParseCredentialsFromJSON(raw, options...)
+	}
+
+	source, err := NewServiceAccountTokenSource(raw, append(options, WithResolverScopes(cloudPlatformScope))...)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	return Credentials{AccessID: parsed.ClientEmail, TokenSource: source}, nil
+}
+
+// impersonatedTokenSource uses the base credentials to request an access token for another service account from the
+// IAM Credentials API (which requires the roles/iam.serviceAccountTokenCreator role on the target).
+// https://cloud.google.com/iam/docs/reference/credentials/rest/v1/projects.serviceAccounts/generateAccessToken
+type impersonatedTokenSource struct {
+	client    httpClient
+	base      Credentials
+	url       string
+	scopes    []string
+	delegates []string
+	lifetime  time.Duration
+	now       func() time.Time
+}
+
+func (this *impersonatedTokenSource) Token(ctx context.Context) (AccessToken, error) {
+	bearerToken, err := this.base.bearerToken(ctx)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	raw, _ := json.Marshal(struct {
+		Delegates []string `json:"delegates,omitempty"`
+		Scope     []string `json:"scope"`
+		Lifetime  string   `json:"lifetime,omitempty"`
+	}{Delegates: this.delegates, Scope: this.scopes, Lifetime: formatLifetime(this.lifetime)})
+
+	request, err := http.NewRequest("POST", this.url, bytes.NewReader(raw))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	request.Header.Set("Authorization", bearerToken)
+	request.Header.Set(headerContentType, "application/json")
+	response, err := this.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	defer drain(response)
+	if err = ParseErrorResponse(response); err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %w", ErrFailedTokenRequest, err)
+	}
+
+	var body struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return AccessToken{}, fmt.Errorf("unable to parse response body returned from the IAM Credentials API: %w", err)
+	}
+
+	return AccessToken{Value: body.AccessToken, Type: "Bearer", Expiration: expiresIn(body.ExpireTime, this.now())}, nil
+}
+
+// qualifyDelegates expands service account emails into the resource names expected by the IAM Credentials API.
+func qualifyDelegates(values []string) (qualified []string) {
+	for _, value := range values {
+		if !strings.HasPrefix(value, "projects/") {
+			value = "projects/-/serviceAccounts/" + value
+		}
+		qualified = append(qualified, value)
+	}
+	return qualified
+}
+
+// expiresIn converts an absolute expiration into the relative form (seconds) of an OAuth token response.
+func expiresIn(expiration, now time.Time) uint16 {
+	return uint16(min(max(expiration.Sub(now).Seconds(), 0), math.MaxUint16))
+}
+func formatLifetime(value time.Duration) string {
+	if value <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%ds", int(value/time.Second))
+}
+
+const (
+	impersonatedServiceAccountType = "impersonated_service_account"
+	serviceAccountType             = "service_account"
+	cloudPlatformScope             = "https://www.googleapis.com/auth/cloud-platform"
+	impersonationURLFormat         = iamCredentialsURL + "%s:generateAccessToken"
+	iamCredentialsURL              = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/"
+)
+
+var ErrBearerCredentialsRequired = errors.New("base credentials must provide a bearer token")
@@ -0,0 +1,144 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestImpersonatedCredentials(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, sampleImpersonationResponseJSON, nil)}}
+	base := Credentials{BearerToken: "Bearer base-token"}
+
+	credentials, err := NewImpersonatedCredentials(base, "target@project.iam.gserviceaccount.com", WithResolverClient(fake),
+		WithResolverScopes("scope1"), WithResolverLifetime(time.Minute*10), WithResolverDelegates("delegate@project.iam.gserviceaccount.com"))
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "target@project.iam.gserviceaccount.com")
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.Header.Get("Authorization"), should.Equal, "Bearer impersonated-token")
+	generate := fake.requests[0]
+	should.So(t, generate.Method, should.Equal, "POST")
+	should.So(t, generate.URL.String(), should.Equal,
+		"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/target@project.iam.gserviceaccount.com:generateAccessToken")
+	should.So(t, generate.Header.Get("Authorization"), should.Equal, "Bearer base-token")
+	body, _ := io.ReadAll(generate.Body)
+	should.So(t, string(body), should.Equal,
+		`{"delegates":["projects/-/serviceAccounts/delegate@project.iam.gserviceaccount.com"],"scope":["scope1"],"lifetime":"600s"}`)
+}
+func TestImpersonatedCredentials_TokenCached(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, sampleImpersonationResponseJSON, nil)}}
+	credentials, _ := NewImpersonatedCredentials(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com", WithResolverClient(fake))
+
+	_, _ = credentials.bearerToken(context.Background())
+	_, _ = credentials.bearerToken(context.Background())
+
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestImpersonatedCredentials_Rejected(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusForbidden, `{"error": {"code": 403, "message": "Permission denied", "status": "PERMISSION_DENIED"}}`, nil)}}
+	credentials, _ := NewImpersonatedCredentials(Credentials{BearerToken: "Bearer base-token"}, "target@project.iam.gserviceaccount.com", WithResolverClient(fake))
+
+	_, err := credentials.bearerToken(context.Background())
+
+	should.So(t, errors.Is(err, ErrFailedTokenRequest), should.BeTrue)
+	should.So(t, errors.Is(err, ErrAccessDenied), should.BeTrue)
+}
+func TestImpersonatedCredentials_BaseWithoutBearer(t *testing.T) {
+	base := Credentials{AccessID: "base@project.iam.gserviceaccount.com"} // neither a bearer token nor a private key
+
+	credentials, err := NewImpersonatedCredentials(base, "target@project.iam.gserviceaccount.com")
+
+	should.So(t, err, should.Equal, ErrBearerCredentialsRequired)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+func TestImpersonatedCredentials_BaseServiceAccountKey(t *testing.T) {
+	for _, options := range [][]ResolverOption{nil, {WithResolverSelfSigned(true)}} {
+		fake := &FakeHTTPClient{responses: []*http.Response{
+			newFakeResponse(http.StatusOK, string(sampleClientIdentityResponseJSON), nil),
+			newFakeResponse(http.StatusOK, sampleImpersonationResponseJSON, nil),
+		}}
+		base, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON, options...) // signs requests (or self-signs JWTs)
+
+		credentials, err := NewImpersonatedCredentials(base, "target@project.iam.gserviceaccount.com",
+			WithResolverClient(fake), WithResolverScopes("scope1"))
+		should.So(t, err, should.BeNil)
+		bearerToken, err := credentials.bearerToken(context.Background())
+
+		should.So(t, err, should.BeNil)
+		should.So(t, bearerToken, should.Equal, "Bearer impersonated-token")
+		should.So(t, fake.requests[0].URL.String(), should.Equal, "https://oauth2.googleapis.com/token")
+		body, _ := io.ReadAll(fake.requests[0].Body)
+		form, _ := url.ParseQuery(string(body))
+		_, claims := assertJWT(t, form.Get("assertion"))
+		should.So(t, claims.Issuer, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+		should.So(t, claims.Scope, should.Equal, "https://www.googleapis.com/auth/cloud-platform")
+		should.So(t, fake.requests[1].Header.Get("Authorization"), should.Equal, "ResolvedTokenType ResolvedAccessToken")
+		body, _ = io.ReadAll(fake.requests[1].Body)
+		should.So(t, string(body), should.Equal, `{"scope":["scope1"],"lifetime":"3600s"}`)
+	}
+}
+func TestImpersonatedCredentials_FromJSON_AuthorizedUser(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusOK, string(sampleClientIdentityResponseJSON), nil),
+		newFakeResponse(http.StatusOK, sampleImpersonationResponseJSON, nil),
+	}}
+
+	credentials, err := ParseCredentialsFromJSON(impersonatedServiceAccountJSON(sampleClientIdentityJSON), WithResolverClient(fake))
+	should.So(t, err, should.BeNil)
+	should.So(t, len(fake.requests), should.Equal, 0) // tokens are requested as needed
+	bearerToken, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, bearerToken, should.Equal, "Bearer impersonated-token")
+	should.So(t, credentials.AccessID, should.Equal, "target@project.iam.gserviceaccount.com")
+	should.So(t, fake.requests[0].URL.String(), should.Equal, tokenURL)
+	should.So(t, fake.requests[1].Header.Get("Authorization"), should.Equal, "ResolvedTokenType ResolvedAccessToken")
+	body, _ := io.ReadAll(fake.requests[1].Body)
+	should.So(t, string(body), should.Equal,
+		`{"delegates":["projects/-/serviceAccounts/delegate@project.iam.gserviceaccount.com"],"scope":["https://www.googleapis.com/auth/devstorage.full_control"],"lifetime":"3600s"}`)
+}
+func TestImpersonatedCredentials_FromJSON_ServiceAccount(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{
+		newFakeResponse(http.StatusOK, string(sampleClientIdentityResponseJSON), nil),
+		newFakeResponse(http.StatusOK, sampleImpersonationResponseJSON, nil),
+	}}
+	credentials, _ := ParseCredentialsFromJSON(impersonatedServiceAccountJSON(sampleServiceAccountJSON), WithResolverClient(fake))
+
+	bearerToken, err := credentials.bearerToken(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, bearerToken, should.Equal, "Bearer impersonated-token")
+	should.So(t, fake.requests[0].URL.String(), should.Equal, "https://oauth2.googleapis.com/token")
+	body, _ := io.ReadAll(fake.requests[0].Body)
+	form, _ := url.ParseQuery(string(body))
+	_, claims := assertJWT(t, form.Get("assertion"))
+	should.So(t, claims.Scope, should.Equal, "https://www.googleapis.com/auth/cloud-platform")
+}
+func TestImpersonatedCredentials_FromJSON_Malformed(t *testing.T) {
+	credentials, err := ParseCredentialsFromJSON([]byte(`{"type": "impersonated_service_account"}`))
+
+	should.So(t, err, should.Equal, ErrMalformedJSON)
+	should.So(t, credentials, should.Equal, Credentials{})
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+func impersonatedServiceAccountJSON(source []byte) []byte {
+	return []byte(`{
+		"type": "impersonated_service_account",
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/target@project.iam.gserviceaccount.com:generateAccessToken",
+		"delegates": ["delegate@project.iam.gserviceaccount.com"],
+		"source_credentials": ` + string(source) + `
+	}`)
+}
+
+const sampleImpersonationResponseJSON = `{"accessToken": "impersonated-token", "expireTime": "2099-01-01T00:00:00Z"}`
@@ -7,18 +7,33 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"time"
 )
 
 type defaultResolver struct {
-	client  httpClient
-	context context.Context
+	client    httpClient
+	context   context.Context
+	refresh   bool
+	scopes    []string
+	signed    bool
+	audience  string
+	lifetime  time.Duration
+	delegates []string
+
+	fileReader        fileReader
+	environmentReader environmentReader
 }
 
-func newTokenResolver(options ...ResolverOption) TokenResolver {
+func newTokenResolver(options ...ResolverOption) *defaultResolver {
 	this := &defaultResolver{}
 
 	WithResolverClient(defaultHTTPClient())(this)
 	WithResolverContext(context.Background())(this)
+	WithResolverScopes(defaultScope)(this)
+	WithResolverAudience(defaultAudience)(this)
+	WithResolverLifetime(jwtLifetime)(this)
+	WithResolverFileReader(&externalSystem{})(this)
+	WithResolverEnvironmentReader(&externalSystem{})(this)
 	for _, option := range options {
 		option(this)
 	}
@@ -27,8 +42,11 @@ func newTokenResolver(options ...ResolverOption) TokenResolver {
 }
 
 func (this *defaultResolver) AccessToken(identity ClientIdentity) (AccessToken, error) {
+	return this.accessToken(this.context, identity)
+}
+func (this *defaultResolver) accessToken(ctx context.Context, identity ClientIdentity) (AccessToken, error) {
 	request, _ := http.NewRequest("POST", tokenURL, this.generateRequestBody(identity))
-	request = request.WithContext(this.context)
+	request = request.WithContext(ctx)
 	response, err := this.client.Do(request)
 	return this.processResponse(response, err)
 }
@@ -57,12 +75,26 @@ func (this *defaultResolver) unmarshalBody(source io.Reader) (result AccessToken
 	return result, nil
 }
 
+// identityTokenSource binds a refresh token to the resolver such that tokens are requested using the caller's context.
+type identityTokenSource struct {
+	resolver *defaultResolver
+	identity ClientIdentity
+}
+
+func (this *identityTokenSource) Token(ctx context.Context) (AccessToken, error) {
+	return this.resolver.accessToken(ctx, this.identity)
+}
+
 type accessTokenRequest struct {
 	ClientIdentity
 	GrantType string `json:"grant_type"`
 }
 
-const tokenURL = "https://www.googleapis.com/oauth2/v4/token"
+const (
+	tokenURL        = "https://www.googleapis.com/oauth2/v4/token"
+	defaultScope    = "https://www.googleapis.com/auth/devstorage.full_control"
+	defaultAudience = "https://storage.googleapis.com/"
+)
 
 var emptyToken = AccessToken{}
 var ErrFailedTokenRequest = errors.New("unable to resolve access token")
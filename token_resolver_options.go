@@ -16,6 +16,53 @@ func WithResolverContext(value context.Context) ResolverOption {
 	return func(this *defaultResolver) { this.context = value }
 }
 
+// WithResolverScopes sets the OAuth scopes requested by token sources which mint their own access tokens (e.g.
+// service accounts); the default is full control of Cloud Storage.
+func WithResolverScopes(values ...string) ResolverOption {
+	return func(this *defaultResolver) { this.scopes = values }
+}
+
+// WithResolverSelfSigned causes ParseCredentialsFromJSON to return Credentials for service accounts which mint
+// self-signed JWTs as access tokens (see NewSelfSignedTokenSource) rather than signing each request.
+func WithResolverSelfSigned(value bool) ResolverOption {
+	return func(this *defaultResolver) { this.signed = value }
+}
+
+// WithResolverAudience sets the "aud" claim of self-signed JWTs; the default is the Cloud Storage API.
+func WithResolverAudience(value string) ResolverOption {
+	return func(this *defaultResolver) { this.audience = value }
+}
+
+// WithResolverLifetime sets the lifetime of self-signed JWTs and impersonated access tokens, which may not exceed one
+// hour.
+func WithResolverLifetime(value time.Duration) ResolverOption {
+	return func(this *defaultResolver) { this.lifetime = min(max(value, time.Minute), jwtLifetime) }
+}
+
+// WithResolverDelegates sets the chain of service accounts (emails or "projects/-/serviceAccounts/{email}") through
+// which impersonated credentials are obtained, each granting the next the roles/iam.serviceAccountTokenCreator role.
+func WithResolverDelegates(values ...string) ResolverOption {
+	return func(this *defaultResolver) { this.delegates = values }
+}
+
+// WithResolverFileReader sets how files named by credentials are read, e.g. the subject token file of external
+// account credentials; the default reads from the file system.
+func WithResolverFileReader(value fileReader) ResolverOption {
+	return func(this *defaultResolver) { this.fileReader = value }
+}
+
+// WithResolverEnvironmentReader sets how environment variables consulted by credentials are read, e.g.
+// GOOGLE_EXTERNAL_ACCOUNT_ALLOW_EXECUTABLES; the default reads from the process environment.
+func WithResolverEnvironmentReader(value environmentReader) ResolverOption {
+	return func(this *defaultResolver) { this.environmentReader = value }
+}
+
+// WithResolverRefresh causes ParseCredentialsFromJSON to return Credentials with a (caching) TokenSource which
+// resolves the access token as requests are built, rather than resolving a single, static access token up front.
+func WithResolverRefresh(value bool) ResolverOption {
+	return func(this *defaultResolver) { this.refresh = value }
+}
+
 func defaultHTTPClient() *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
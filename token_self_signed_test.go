@@ -0,0 +1,69 @@
+package gcs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestSelfSignedTokenSource(t *testing.T) {
+	source, err := NewSelfSignedTokenSource(sampleServiceAccountJSON,
+		WithResolverAudience("https://example.com/"), WithResolverLifetime(time.Minute*10))
+	should.So(t, err, should.BeNil)
+
+	token, err := source.Token(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, token.Type, should.Equal, "Bearer")
+	should.So(t, token.Expiration, should.Equal, uint16(600))
+	header, claims := assertJWT(t, token.Value)
+	should.So(t, header["kid"], should.Equal, "6c264308c88338cee18d31da2290c30e99e01839")
+	should.So(t, claims.Issuer, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	should.So(t, claims.Subject, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	should.So(t, claims.Audience, should.Equal, "https://example.com/")
+	should.So(t, claims.Scope, should.Equal, "")
+	should.So(t, claims.ExpiresAt-claims.IssuedAt, should.Equal, int64(600))
+}
+func TestSelfSignedTokenSource_Cached(t *testing.T) {
+	source, _ := NewSelfSignedTokenSource(sampleServiceAccountJSON)
+
+	first, _ := source.Token(context.Background())
+	second, _ := source.Token(context.Background())
+
+	should.So(t, first, should.Equal, second)
+}
+func TestSelfSignedTokenSource_LifetimeLimitedToOneHour(t *testing.T) {
+	source, _ := NewSelfSignedTokenSource(sampleServiceAccountJSON, WithResolverLifetime(time.Hour*2))
+
+	token, _ := source.Token(context.Background())
+
+	should.So(t, token.Expiration, should.Equal, uint16(3600))
+}
+func TestSelfSignedCredentials(t *testing.T) {
+	credentials, err := ParseCredentialsFromJSON(sampleServiceAccountJSON, WithResolverSelfSigned(true))
+	should.So(t, err, should.BeNil)
+	should.So(t, credentials.AccessID, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+
+	request, _ := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"), WithCredentials(credentials))
+
+	authorization := request.Header.Get("Authorization")
+	should.So(t, authorization[:len("Bearer ")], should.Equal, "Bearer ")
+	_, claims := assertJWT(t, authorization[len("Bearer "):])
+	should.So(t, claims.Audience, should.Equal, "https://storage.googleapis.com/")
+	should.So(t, request.URL.Query().Get("Signature"), should.Equal, "")
+}
+func TestSelfSignedCredentials_RetainPrivateKeyForSigning(t *testing.T) {
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON, WithResolverSelfSigned(true))
+	signing := credentials
+	signing.TokenSource = nil // sign the URL rather than presenting the self-signed JWT
+	frozen := time.Unix(1554410829, 0)
+
+	request, err := NewRequest(GET, WithBucket("bucket"), WithResource("file.txt"),
+		WithCredentials(signing), WithSignedExpiration(frozen))
+
+	should.So(t, err, should.BeNil)
+	should.So(t, request.URL.Query().Get("GoogleAccessId"), should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	assertSignatureV2(t, credentials, request.URL.Query().Get("Signature"), "GET\n\n\n1554410829\n/bucket/file.txt")
+}
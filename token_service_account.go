@@ -0,0 +1,129 @@
+package gcs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NewServiceAccountTokenSource exchanges a JWT, signed by the private key of the service account described by the
+// JSON provided, for an OAuth access token using the JWT bearer grant (RFC 7523). Tokens are cached until shortly
+// before they expire. The scopes requested may be set using WithResolverScopes.
+// https://developers.google.com/identity/protocols/oauth2/service-account#httprest
+func NewServiceAccountTokenSource(raw []byte, options ...ResolverOption) (TokenSource, error) {
+	parsed, err := unmarshalClientCredentials(raw)
+	if err != nil {
+		return nil, err
+	} else if len(parsed.ClientEmail) == 0 || len(parsed.PrivateKeyPEM) == 0 {
+		return nil, ErrServiceAccountRequired
+	}
+
+	key, err := newPrivateKey([]byte(parsed.PrivateKeyPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenURI := parsed.TokenURI
+	if len(tokenURI) == 0 {
+		tokenURI = serviceAccountTokenURL
+	}
+
+	return NewCachingTokenSource(&serviceAccountTokenSource{
+		resolver: newTokenResolver(options...),
+		key:      key,
+		email:    parsed.ClientEmail,
+		keyID:    parsed.PrivateKeyID,
+		tokenURI: tokenURI,
+		now:      time.Now,
+	}), nil
+}
+
+// newKeyTokenSource obtains access tokens (scoped for the IAM Credentials API) using the private key of the credentials
+// provided, such that signing credentials may serve as the base of impersonated credentials.
+func newKeyTokenSource(credentials Credentials, resolver *defaultResolver) TokenSource {
+	source := *resolver
+	source.scopes = []string{cloudPlatformScope}
+	return NewCachingTokenSource(&serviceAccountTokenSource{
+		resolver: &source,
+		key:      credentials.PrivateKey,
+		email:    credentials.AccessID,
+		tokenURI: serviceAccountTokenURL,
+		now:      time.Now,
+	})
+}
+
+type serviceAccountTokenSource struct {
+	resolver *defaultResolver
+	key      PrivateKey
+	email    string
+	keyID    string
+	tokenURI string
+	now      func() time.Time
+}
+
+func (this *serviceAccountTokenSource) Token(ctx context.Context) (AccessToken, error) {
+	issuedAt := this.now()
+	assertion, err := signJWT(&this.key, this.keyID, jwtClaims{
+		Issuer:    this.email,
+		Audience:  this.tokenURI,
+		Scope:     strings.Join(this.resolver.scopes, " "),
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: issuedAt.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	body := url.Values{"grant_type": {jwtBearerGrantType}, "assertion": {assertion}}
+	request, err := http.NewRequest("POST", this.tokenURI, strings.NewReader(body.Encode()))
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	request.Header.Set(headerContentType, "application/x-www-form-urlencoded")
+	response, err := this.resolver.client.Do(request.WithContext(ctx))
+	return this.resolver.processResponse(response, err)
+}
+
+/* ////////////////////////////////////////////////////////////////////////////////////////////////////////////////// */
+
+type jwtClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub,omitempty"`
+	Audience  string `json:"aud"`
+	Scope     string `json:"scope,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// signJWT produces a compact, RS256-signed JSON Web Token.
+func signJWT(key *PrivateKey, keyID string, claims jwtClaims) (string, error) {
+	header, _ := json.Marshal(struct {
+		Algorithm string `json:"alg"`
+		Type      string `json:"typ"`
+		KeyID     string `json:"kid,omitempty"`
+	}{Algorithm: "RS256", Type: "JWT", KeyID: keyID})
+	payload, _ := json.Marshal(claims)
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if signature, err := key.Sign([]byte(unsigned)); err != nil {
+		return "", err
+	} else if len(signature) == 0 {
+		return "", ErrServiceAccountRequired
+	} else {
+		return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+	}
+}
+
+const (
+	jwtBearerGrantType     = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	jwtLifetime            = time.Hour
+	serviceAccountTokenURL = "https://oauth2.googleapis.com/token"
+)
+
+var ErrServiceAccountRequired = errors.New("service account email and private key are required")
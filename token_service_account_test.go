@@ -0,0 +1,86 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/smarty/gcs/internal/should"
+)
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, string(sampleClientIdentityResponseJSON), nil)}}
+	source, err := NewServiceAccountTokenSource(sampleServiceAccountJSON, WithResolverClient(fake), WithResolverScopes("scope1", "scope2"))
+	should.So(t, err, should.BeNil)
+
+	token, err := source.Token(context.Background())
+
+	should.So(t, err, should.BeNil)
+	should.So(t, token.Value, should.Equal, "ResolvedAccessToken")
+	request := fake.requests[0]
+	should.So(t, request.Method, should.Equal, "POST")
+	should.So(t, request.URL.String(), should.Equal, "https://oauth2.googleapis.com/token")
+	should.So(t, request.Header.Get("Content-Type"), should.Equal, "application/x-www-form-urlencoded")
+	body, _ := io.ReadAll(request.Body)
+	form, _ := url.ParseQuery(string(body))
+	should.So(t, form.Get("grant_type"), should.Equal, "urn:ietf:params:oauth:grant-type:jwt-bearer")
+
+	header, claims := assertJWT(t, form.Get("assertion"))
+	should.So(t, header["alg"], should.Equal, "RS256")
+	should.So(t, header["kid"], should.Equal, "6c264308c88338cee18d31da2290c30e99e01839")
+	should.So(t, claims.Issuer, should.Equal, "sample-key@project-id-here.iam.gserviceaccount.com")
+	should.So(t, claims.Audience, should.Equal, "https://oauth2.googleapis.com/token")
+	should.So(t, claims.Scope, should.Equal, "scope1 scope2")
+	should.So(t, claims.ExpiresAt-claims.IssuedAt, should.Equal, int64(3600))
+}
+func TestServiceAccountTokenSource_TokenCached(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusOK, string(sampleClientIdentityResponseJSON), nil)}}
+	source, _ := NewServiceAccountTokenSource(sampleServiceAccountJSON, WithResolverClient(fake))
+
+	_, _ = source.Token(context.Background())
+	_, _ = source.Token(context.Background())
+
+	should.So(t, len(fake.requests), should.Equal, 1)
+}
+func TestServiceAccountTokenSource_Rejected(t *testing.T) {
+	fake := &FakeHTTPClient{responses: []*http.Response{newFakeResponse(http.StatusBadRequest, `{"error":"invalid_grant"}`, nil)}}
+	source, _ := NewServiceAccountTokenSource(sampleServiceAccountJSON, WithResolverClient(fake))
+
+	token, err := source.Token(context.Background())
+
+	should.So(t, err, should.Equal, ErrFailedTokenRequest)
+	should.So(t, token, should.Equal, AccessToken{})
+}
+func TestServiceAccountTokenSource_NotAServiceAccount(t *testing.T) {
+	source, err := NewServiceAccountTokenSource(sampleClientIdentityJSON)
+
+	should.So(t, err, should.Equal, ErrServiceAccountRequired)
+	should.So(t, source, should.BeNil)
+}
+
+func assertJWT(t *testing.T, token string) (header map[string]any, claims jwtClaims) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	should.So(t, len(parts), should.Equal, 3)
+
+	rawHeader, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	rawClaims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	_ = json.NewDecoder(bytes.NewReader(rawHeader)).Decode(&header)
+	_ = json.Unmarshal(rawClaims, &claims)
+
+	credentials, _ := ParseCredentialsFromJSON(sampleServiceAccountJSON)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err := rsa.VerifyPKCS1v15(&credentials.PrivateKey.inner.PublicKey, crypto.SHA256, digest[:], signature)
+	should.So(t, err, should.BeNil)
+	return header, claims
+}